@@ -1,33 +1,74 @@
 package api
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"godab/config"
-	"math/rand"
+	"godab/playlist"
 	"os"
+	"path/filepath"
+	"slices"
 	"sync"
-	"time"
 
 	"github.com/jedib0t/go-pretty/v6/progress"
 )
 
 type Album struct {
-	Id          string  `json:"id"`
-	Title       string  `json:"title"`
-	Artist      string  `json:"artist"`
-	Cover       string  `json:"cover"`
-	ReleaseDate string  `json:"releaseDate"`
-	TrackCount  int     `json:"trackCount"`
-	Tracks      []Track `json:"tracks"`
+	Id           string       `json:"id"`
+	Title        string       `json:"title"`
+	Artist       string       `json:"artist"`
+	ArtistId     ID           `json:"artistId"`
+	Cover        string       `json:"cover"`
+	ReleaseDate  string       `json:"releaseDate"`
+	TrackCount   int          `json:"trackCount"`
+	DiscCount    int          `json:"mediaCount"`
+	Genre        string       `json:"genre"`
+	Label        string       `json:"label"`
+	UPC          string       `json:"upc"`
+	Copyright    string       `json:"copyright"`
+	Explicit     bool         `json:"explicit"`
+	ReleaseType  string       `json:"releaseType,omitempty"`
+	AudioQuality AudioQuality `json:"audioQuality"`
+	Tracks       []Track      `json:"tracks"`
+
+	client *Client
 }
 
-func NewAlbum(albumId string) (*Album, error) {
+func (album *Album) UnmarshalJSON(data []byte) error {
+	type plain Album
+
+	aux := struct {
+		*plain
+		Genre           json.RawMessage `json:"genre"`
+		Label           json.RawMessage `json:"label"`
+		ParentalWarning bool            `json:"parental_warning"`
+		ReleaseType     string          `json:"release_type"`
+	}{plain: (*plain)(album)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	album.Genre = decodeName(aux.Genre)
+	album.Label = decodeName(aux.Label)
+	album.Explicit = album.Explicit || aux.ParentalWarning
+	album.ReleaseType = cmp.Or(album.ReleaseType, aux.ReleaseType)
+
+	return nil
+}
+
+func (c *Client) NewAlbum(albumId string) (*Album, error) {
+	return c.NewAlbumContext(context.Background(), albumId)
+}
+
+func (c *Client) NewAlbumContext(ctx context.Context, albumId string) (*Album, error) {
 	type Response struct {
 		Album Album `json:"album"`
 	}
 
-	res, err := _request("api/album", true, []QueryParams{
+	res, err := c._request(ctx, "api/album", true, []QueryParams{
 		{Name: "albumId", Value: albumId},
 	})
 
@@ -44,48 +85,136 @@ func NewAlbum(albumId string) (*Album, error) {
 	}
 
 	if response.Album.Id == "0" {
-		return nil, fmt.Errorf("album not found")
+		return nil, fmt.Errorf("album %s %w", albumId, ErrNotFound)
 	}
 
+	response.Album.bind(c)
+	response.Album.fillTracks()
+
 	return &response.Album, nil
 }
 
-func (album *Album) downloadAlbum(format int, rc RenderContext) error {
-	outputLocation := config.GetDownloadLocation()
+// fillTracks copies the album wide details to its tracks and numbers the
+// tracks the API returned without position.
+func (album *Album) fillTracks() {
+	discTracks := make(map[int]int)
+	discTotal := album.DiscCount
 
-	if !DirExists(outputLocation) {
-		return fmt.Errorf("specified location for file downloads doesn't exists")
+	for i := range album.Tracks {
+		track := &album.Tracks[i]
+
+		if track.TrackNumber == 0 {
+			track.TrackNumber = i + 1
+		}
+		if track.DiscNumber == 0 {
+			track.DiscNumber = 1
+		}
+
+		discTracks[track.DiscNumber]++
+		discTotal = max(discTotal, track.DiscNumber)
 	}
 
-	var rootFolder = fmt.Sprintf("%s/%s", outputLocation, SanitizeFilename(album.Artist))
+	for i := range album.Tracks {
+		track := &album.Tracks[i]
+
+		if track.TrackTotal == 0 {
+			track.TrackTotal = discTracks[track.DiscNumber]
+		}
+		if track.DiscTotal == 0 {
+			track.DiscTotal = discTotal
+		}
+		track.AlbumArtist = cmp.Or(track.AlbumArtist, album.Artist)
+		track.Album = cmp.Or(track.Album, album.Title)
+		track.AlbumId = cmp.Or(track.AlbumId, album.Id)
+		track.Cover = cmp.Or(track.Cover, album.Cover)
+		track.ReleaseDate = cmp.Or(track.ReleaseDate, album.ReleaseDate)
+		track.Genre = cmp.Or(track.Genre, album.Genre)
+		track.Label = cmp.Or(track.Label, album.Label)
+		track.UPC = cmp.Or(track.UPC, album.UPC)
+		track.Copyright = cmp.Or(track.Copyright, album.Copyright)
+	}
+}
 
-	if !DirExists(rootFolder) {
-		os.Mkdir(rootFolder, 0755)
+func (album *Album) bind(c *Client) {
+	album.client = c
+	for i := range album.Tracks {
+		album.Tracks[i].client = c
 	}
+}
 
-	var albumLocation = fmt.Sprintf("%s/%s", rootFolder, SanitizeFilename(album.Title))
+// downloadAlbum returns the playlist items of the album tracks found on
+// disk once done.
+func (c *Client) downloadAlbum(ctx context.Context, album *Album, format int, rc RenderContext) ([]playlist.Item, error) {
+	outputLocation := c.downloadLocation()
 
-	if DirExists(albumLocation) {
-		return fmt.Errorf("album directory already exists")
+	if !DirExists(outputLocation) {
+		return nil, fmt.Errorf("specified location for file downloads doesn't exists")
 	}
 
-	err := os.Mkdir(albumLocation, 0755)
+	template, err := ParseTemplate(c.albumTemplate())
 	if err != nil {
-		return fmt.Errorf("can't create dir %s", albumLocation)
+		return nil, err
 	}
 
-	progressChan := make(chan int, len(album.Tracks))
+	locations := make(map[ID]string)
+	var paths []string
+
+	album.fillTracks()
 
 	for i := range album.Tracks {
-		album.Tracks[i].TrackNumber = i + 1
+		track := &album.Tracks[i]
+
+		location := existingLocation(filepath.Join(outputLocation, template.Render(templateFields(track, album))) + "." + qualityExtension(format))
+		locations[track.Id] = location
+		paths = append(paths, location)
+	}
+
+	var albumLocation = commonDir(paths)
+
+	// Editions of an album can render to the same paths, the second one
+	// waits and finds the files of the first like a later run would.
+	claims := slices.Clone(paths)
+	if albumLocation != filepath.Clean(outputLocation) {
+		claims = append(claims, albumLocation)
+	}
+
+	pool := c.workers()
+	if err := pool.acquirePaths(ctx, claims); err != nil {
+		return nil, fmt.Errorf("download of album %s interrupted: %w", album.Title, err)
+	}
+	defer pool.releasePaths(claims)
+
+	// A directory holding .part files belongs to an interrupted download,
+	// which is resumed instead of refused.
+	if albumLocation != filepath.Clean(outputLocation) && DirExists(albumLocation) && !hasPartFiles(albumLocation) && c.Options.Existing == ExistingFail && !c.Options.Verify {
+		return nil, fmt.Errorf("album directory already exists")
+	}
+
+	var tracksToDownload []Track
+
+	for _, track := range album.Tracks {
+		location := locations[track.Id]
+
+		if c.skipExisting(ctx, &track, location, format) {
+			c.emit(TrackResult{Track: track, Location: location, Skipped: true})
+
+			if rc.Mode == ModeArtistDownload {
+				rc.Tracker.Increment(1)
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+			return nil, fmt.Errorf("can't create dir %s", filepath.Dir(location))
+		}
+
+		tracksToDownload = append(tracksToDownload, track)
 	}
 
 	maxRetries := 3
-	maxConcurrent := 3
-	var failedTracks []Track
-	tracksToDownload := album.Tracks
+	var failedTracks []TrackResult
 
-	trackers := make([]*progress.Tracker, 0)
+	trackers := make(map[ID]*progress.Tracker)
 
 	switch rc.Mode {
 	case ModeAlbumDownload:
@@ -94,15 +223,18 @@ func (album *Album) downloadAlbum(format int, rc RenderContext) error {
 		pw.SetNumTrackersExpected(len(tracksToDownload))
 		pw.Style().Visibility.TrackerOverall = true
 
-		trackers = GetTrackersTrackSizes(tracksToDownload, format)
-		pw.AppendTrackers(trackers)
+		sizes := c.GetTrackersTrackSizes(ctx, tracksToDownload, format)
+		for idx, track := range tracksToDownload {
+			trackers[track.Id] = sizes[idx]
+		}
+		pw.AppendTrackers(sizes)
 
-		go pw.Render()
+		c.render(pw)
 	case ModeArtistDownload:
-		rc.Pw.SetNumTrackersExpected(len(tracksToDownload))
+		rc.Pw.SetNumTrackersExpected(len(album.Tracks))
 	}
 
-	for i := 0; i < maxRetries; i++ {
+	for i := 0; i < maxRetries && ctx.Err() == nil; i++ {
 		if len(tracksToDownload) == 0 {
 			break
 		}
@@ -112,76 +244,112 @@ func (album *Album) downloadAlbum(format int, rc RenderContext) error {
 		}
 
 		var wg sync.WaitGroup
-		sem := make(chan struct{}, maxConcurrent)
-		failedTracksChan := make(chan Track, len(tracksToDownload))
+		failedTracksChan := make(chan TrackResult, len(tracksToDownload))
+		// locations is only touched here, the workers send theirs back
+		doneTracksChan := make(chan TrackResult, len(tracksToDownload))
 
-		for idx, track := range tracksToDownload {
-			wg.Add(1)
-			sem <- struct{}{}
+		for _, track := range tracksToDownload {
+			location := locations[track.Id]
 
-			var trk *progress.Tracker = nil
-			if rc.Mode == ModeAlbumDownload {
-				trk = trackers[idx]
+			if err := pool.acquireTrack(ctx, c.concurrency()); err != nil {
+				failedTracksChan <- TrackResult{Track: track, Location: location, Err: err}
+				continue
 			}
 
+			wg.Add(1)
 			go func(track Track, tk *progress.Tracker) {
 				defer wg.Done()
-				defer func() { <-sem }()
-
-				var trackName string
-				if track.TrackNumber < 10 {
-					trackName = fmt.Sprintf("0%d - %s", track.TrackNumber, SanitizeFilename(track.Title))
-				} else {
-					trackName = fmt.Sprintf("%d - %s", track.TrackNumber, SanitizeFilename(track.Title))
-				}
+				defer pool.releaseTrack()
 
-				fileFormat := "flac"
-
-				if format == 5 {
-					fileFormat = "mp3"
-				}
-
-				location := fmt.Sprintf("%s/%s.%s", albumLocation, trackName, fileFormat)
-				err := track.downloadTrack(location, format, tk)
+				location, quality, err := c.downloadTrack(ctx, &track, location, format, tk)
 
 				if rc.Mode == ModeArtistDownload {
 					rc.Tracker.Increment(1)
 				}
 
 				if err != nil {
-					failedTracksChan <- track
+					failedTracksChan <- TrackResult{Track: track, Location: location, Err: err}
 				} else {
-					progressChan <- 1
+					result := TrackResult{Track: track, Location: location, Quality: quality}
+					doneTracksChan <- result
+					c.emit(result)
 				}
-				time.Sleep(time.Duration(rand.Intn(1500)+500) * time.Millisecond)
-			}(track, trk)
+			}(track, trackers[track.Id])
 		}
 
 		wg.Wait()
 		close(failedTracksChan)
+		close(doneTracksChan)
+
+		// the delivered format can change the extension
+		for result := range doneTracksChan {
+			locations[result.Track.Id] = result.Location
+		}
 
 		failedTracks = nil
+		tracksToDownload = nil
 		for failedTrack := range failedTracksChan {
 			failedTracks = append(failedTracks, failedTrack)
+
+			// only interrupted transfers, requests are already retried
+			var transferErr *transferError
+			if errors.As(failedTrack.Err, &transferErr) {
+				tracksToDownload = append(tracksToDownload, failedTrack.Track)
+			}
 		}
+	}
+
+	for _, result := range failedTracks {
+		c.emit(result)
+	}
 
-		tracksToDownload = failedTracks
+	if ctx.Err() != nil {
+		// Only succeeds when nothing was downloaded yet.
+		for _, location := range paths {
+			os.Remove(filepath.Dir(location))
+		}
+		os.Remove(albumLocation)
+		return nil, fmt.Errorf("download of album %s interrupted: %w", album.Title, ctx.Err())
 	}
 
-	close(progressChan)
+	var items []playlist.Item
+	for _, track := range album.Tracks {
+		if FileExists(locations[track.Id]) {
+			items = append(items, playlistItem(&track, locations[track.Id]))
+		}
+	}
+
+	if c.Options.WritePlaylists && len(items) > 0 {
+		location := filepath.Join(albumLocation, SanitizeFilename(album.Title)+".m3u8")
+		if err := playlist.WriteM3U8(location, album.Title, items); err != nil {
+			return items, fmt.Errorf("unable to write album playlist: %w", err)
+		}
+	}
 
 	if len(failedTracks) > 0 {
 		var errorMessages []string
-		for _, track := range failedTracks {
-			errorMessages = append(errorMessages, fmt.Sprintf("'%s' (ID: %d)", track.Title, track.Id))
+		for _, result := range failedTracks {
+			errorMessages = append(errorMessages, fmt.Sprintf("'%s' (ID: %d)", result.Track.Title, result.Track.Id))
 		}
-		return fmt.Errorf("completed with %d errors. Failed to download tracks: %s", len(failedTracks), errorMessages)
+		return items, fmt.Errorf("completed with %d errors. Failed to download tracks: %s, last error: %w", len(failedTracks), errorMessages, failedTracks[len(failedTracks)-1].Err)
 	}
 
-	return nil
+	return items, nil
 }
 
 func (album *Album) Download(format int, log bool) error {
+	return album.DownloadContext(context.Background(), format, log)
+}
+
+func (album *Album) DownloadContext(ctx context.Context, format int, log bool) error {
+	return clientOrDefault(album.client).DownloadAlbumContext(ctx, album, format, log)
+}
+
+func (c *Client) DownloadAlbum(album *Album, format int, log bool) error {
+	return c.DownloadAlbumContext(context.Background(), album, format, log)
+}
+
+func (c *Client) DownloadAlbumContext(ctx context.Context, album *Album, format int, log bool) error {
 	if log {
 		PrintColor(COLOR_GREEN, "Starting download for album %s\n", album.Title)
 	}
@@ -191,8 +359,9 @@ func (album *Album) Download(format int, log bool) error {
 		Pw:   pw,
 		Mode: ModeAlbumDownload,
 	}
+	defer StopProgress(pw)
 
-	err := album.downloadAlbum(format, rc)
+	_, err := c.downloadAlbum(ctx, album, format, rc)
 
 	if err != nil {
 		return fmt.Errorf("%w", err)
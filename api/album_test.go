@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testFLAC is a FLAC stream without audio frames, enough to be tagged.
+func testFLAC() []byte {
+	streamInfo := make([]byte, 34)
+	binary.BigEndian.PutUint16(streamInfo[0:], 4096)
+	binary.BigEndian.PutUint16(streamInfo[2:], 4096)
+	// 44.1kHz, 2 channels, 16 bits, no samples
+	binary.BigEndian.PutUint64(streamInfo[10:], 44100<<44|1<<41|15<<36)
+
+	return append([]byte("fLaC\x80\x00\x00\x22"), streamInfo...)
+}
+
+// testServer fakes the API and the CDN for an album of tracks tracks. It
+// records the most requests to the CDN running at once.
+type testServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	running     int
+	maxRunning  int
+	transferLag time.Duration
+}
+
+func newTestServer(t *testing.T, tracks int) *testServer {
+	t.Helper()
+
+	server := &testServer{}
+	file := testFLAC()
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/album", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("albumId")
+
+		var list []map[string]any
+		for i := 1; i <= tracks; i++ {
+			list = append(list, map[string]any{
+				"id": i, "title": fmt.Sprintf("Song %d", i), "artist": "Artist", "trackNumber": i,
+			})
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"album": map[string]any{
+			"id": id, "title": "Album " + id, "artist": "Artist", "trackCount": tracks, "tracks": list,
+		}})
+	})
+
+	mux.HandleFunc("/api/stream", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"url": server.URL + "/file/" + r.URL.Query().Get("trackId")})
+	})
+
+	mux.HandleFunc("/file/", func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.running++
+		server.maxRunning = max(server.maxRunning, server.running)
+		server.mu.Unlock()
+
+		defer func() {
+			server.mu.Lock()
+			server.running--
+			server.mu.Unlock()
+		}()
+
+		time.Sleep(server.transferLag)
+		http.ServeContent(w, r, "track.flac", time.Unix(0, 0), bytes.NewReader(file))
+	})
+
+	server.Server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (s *testServer) client(t *testing.T, options Options) *Client {
+	t.Helper()
+
+	options.DownloadLocation = t.TempDir()
+	options.HideProgress = true
+
+	return NewClient(s.URL, options)
+}
+
+func TestDownloadAlbumTracks(t *testing.T) {
+	const tracks = 12
+
+	server := newTestServer(t, tracks)
+
+	var mu sync.Mutex
+	var results []TrackResult
+
+	c := server.client(t, Options{
+		Concurrency: 4,
+		OnTrackResult: func(result TrackResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		},
+	})
+
+	album, err := c.NewAlbumContext(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DownloadAlbumContext(context.Background(), album, QualityCD, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != tracks {
+		t.Fatalf("got %d results, want %d", len(results), tracks)
+	}
+
+	for _, result := range results {
+		if result.Err != nil || result.Quality != QualityCD {
+			t.Errorf("track %d: error %v, quality %d", result.Track.Id, result.Err, result.Quality)
+		}
+		if !FileExists(result.Location) {
+			t.Errorf("track %d: %s is missing", result.Track.Id, result.Location)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(c.Options.DownloadLocation, "Artist", "Album 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".part") {
+			t.Errorf("%s left behind", entry.Name())
+		}
+	}
+
+	if len(entries) != tracks {
+		t.Errorf("got %d files, want %d", len(entries), tracks)
+	}
+}
+
+func TestHostConcurrency(t *testing.T) {
+	server := newTestServer(t, 8)
+	server.transferLag = 20 * time.Millisecond
+
+	c := server.client(t, Options{Concurrency: 8, HostConcurrency: 2, Existing: ExistingSkip, Verify: true})
+
+	album, err := c.NewAlbumContext(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sizes, downloads, then the verification of the downloaded tracks
+	for range 2 {
+		if err := c.DownloadAlbumContext(context.Background(), album, QualityCD, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if server.maxRunning > 2 {
+		t.Errorf("%d requests to the host at once, want at most 2", server.maxRunning)
+	}
+}
@@ -2,25 +2,29 @@ package api
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"godab/config"
 	"io"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.senan.xyz/taglib"
 )
 
 type ID int
 
+// Custom tags identifying the dabmusic track and album a file was downloaded
+// from, used by Retagger to refresh metadata later on.
+const (
+	TrackIdTag = "DABMUSIC_TRACKID"
+	AlbumIdTag = "DABMUSIC_ALBUMID"
+)
+
 type AlbumsResults struct {
 	Items []Album `json:"albums"`
 }
@@ -30,13 +34,24 @@ type TrackResults struct {
 }
 
 type ArtistResults struct {
-	Items []Artist
+	Items []Artist `json:"artists"`
 }
 
 type SearchResults struct {
-	Tracks  TrackResults
-	Albums  AlbumsResults
-	Artists ArtistResults
+	Tracks  TrackResults  `json:"tracks,omitzero"`
+	Albums  AlbumsResults `json:"albums,omitzero"`
+	Artists ArtistResults `json:"artists,omitzero"`
+}
+
+// AudioQuality is the best quality a track or album is available in.
+type AudioQuality struct {
+	MaximumBitDepth     int     `json:"maximumBitDepth"`
+	MaximumSamplingRate float64 `json:"maximumSamplingRate"`
+	IsHiRes             bool    `json:"isHiRes"`
+}
+
+func (quality AudioQuality) HiRes() bool {
+	return quality.IsHiRes || quality.MaximumBitDepth > 16
 }
 
 type QueryParams struct {
@@ -45,26 +60,25 @@ type QueryParams struct {
 }
 
 type Metadatas struct {
+	TrackId     ID
+	AlbumId     string
 	Title       string
 	Artist      string
+	AlbumArtist string
 	Album       string
 	Date        string
 	Cover       string
 	TrackNumber int
-}
-
-var jar, _ = cookiejar.New(nil)
-var client = &http.Client{
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			MinVersion: tls.VersionTLS13,
-		},
-		IdleConnTimeout:       config.GetIdleConnTimeout(),
-		TLSHandshakeTimeout:   config.GetTLSHandshakeTimeout(),
-		ExpectContinueTimeout: config.GetExpectContinueTimeout(),
-	},
-	Jar:     jar,
-	Timeout: config.GetTimeout(),
+	TrackTotal  int
+	DiscNumber  int
+	DiscTotal   int
+	Genre       string
+	Label       string
+	ISRC        string
+	UPC         string
+	Copyright   string
+	Composer    string
+	Explicit    bool
 }
 
 func (id *ID) UnmarshalJSON(data []byte) error {
@@ -75,34 +89,51 @@ func (id *ID) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func LoadCookies() (bool, error) {
-	if _, err := os.Stat(".token"); errors.Is(err, os.ErrNotExist) {
-		return false, nil
+// decodeName reads fields the API sends either as a plain string or as an
+// object with a name, like genres and labels.
+func decodeName(data json.RawMessage) string {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		return name
+	}
+
+	var object struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(data, &object) == nil {
+		return object.Name
 	}
 
-	path := filepath.Join(".token")
-	dat, err := os.ReadFile(path)
+	return ""
+}
 
+// LoadCookies restores the session saved by Login for the endpoint of c.
+// It reports false when there is none and ErrSessionExpired when it is
+// known to have expired.
+func (c *Client) LoadCookies() (bool, error) {
+	session, err := c.loadSession()
 	if err != nil {
-		return false, fmt.Errorf("unable to read .token file")
+		return false, err
 	}
 
-	url, _ := url.Parse(config.GetEndpoint())
-	client.Jar.SetCookies(url, []*http.Cookie{
-		{
-			Name:  "session",
-			Value: string(dat),
-		},
-	})
+	if session == nil || session.Token == "" {
+		return false, nil
+	}
 
-	return dat != nil, nil
+	if session.Expired() {
+		return false, ErrSessionExpired
+	}
+
+	c.SetSession(session.Token)
+
+	return true, nil
 }
 
-func _request(path string, isPathOnly bool, params []QueryParams) (resp *http.Response, err error) {
+func (c *Client) _request(ctx context.Context, path string, isPathOnly bool, params []QueryParams) (resp *http.Response, err error) {
 	var fullUrl string
 
 	if isPathOnly {
-		fullUrl = fmt.Sprintf("%s/%s", config.GetEndpoint(), path)
+		fullUrl = fmt.Sprintf("%s/%s", c.Endpoint, strings.TrimPrefix(path, "/"))
 
 		u, err := url.Parse(fullUrl)
 
@@ -121,46 +152,174 @@ func _request(path string, isPathOnly bool, params []QueryParams) (resp *http.Re
 		fullUrl = path
 	}
 
-	req, err := http.NewRequest(http.MethodGet, fullUrl, nil)
-	if err != nil {
-		return nil, fmt.Errorf("can't create request: %w", err)
+	limiter := c.rateLimiter()
+	relogged := false
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.wait(ctx, c.Options.RequestsPerSecond, c.Options.RequestBurst); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
+		if err != nil {
+			return nil, fmt.Errorf("can't create request: %w", err)
+		}
+
+		session := c.Session()
+		res, err := c.do(req)
+
+		if err != nil {
+			if ctx.Err() != nil || attempt == maxRequestRetries || !temporaryError(err) {
+				return nil, fmt.Errorf("can't fetch endpoint %s: %w", fullUrl, err)
+			}
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if res.StatusCode == http.StatusOK {
+			return res, nil
+		}
+
+		res.Body.Close()
+
+		// the session expired, sent again once logged in with the stored
+		// credentials
+		if res.StatusCode == http.StatusUnauthorized && !relogged {
+			relogged = true
+
+			err := c.relogin(ctx, session)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, ErrNoCredentials) {
+				return nil, fmt.Errorf("%w, %w", &HTTPError{Status: res.StatusCode, URL: fullUrl}, err)
+			}
+		}
+
+		if !retryable(res.StatusCode) || attempt == maxRequestRetries {
+			return nil, &HTTPError{Status: res.StatusCode, URL: fullUrl}
+		}
+
+		// the server tells how long to hold back, every request waits
+		// as it would be refused too
+		delay, ok := retryAfter(res)
+		if ok {
+			limiter.pause(time.Now().Add(delay))
+		} else if err := sleep(ctx, backoff(attempt)); err != nil {
+			return nil, err
+		}
 	}
+}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36")
+// isEndpoint reports whether u is served by the endpoint, the only server
+// the session is sent to.
+func (c *Client) isEndpoint(u *url.URL) bool {
+	endpoint, err := url.Parse(c.Endpoint)
+	return err == nil && u.Scheme == endpoint.Scheme && strings.EqualFold(u.Host, endpoint.Host)
+}
 
-	res, err := client.Do(req)
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", c.UserAgent)
 
-	if err != nil {
-		return nil, fmt.Errorf("can't fetch endpoint %s: %w", fullUrl, err)
+	if session := c.Session(); session != "" && c.isEndpoint(req.URL) {
+		req.AddCookie(&http.Cookie{
+			Name:  "session",
+			Value: session,
+		})
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request to %s failed with status code: %s", fullUrl, res.Status)
+	return c.HTTPClient.Do(req)
+}
+
+// tags maps the metadatas to taglib keys. Vorbis comments keep totals in
+// their own fields while ID3 and MP4 store them as "number/total".
+func (metadatas Metadatas) tags(fileType string) map[string][]string {
+	tags := make(map[string][]string)
+
+	set := func(key string, value string) {
+		if value != "" {
+			tags[key] = []string{value}
+		}
+	}
+
+	position := func(number int, total int) string {
+		if number == 0 {
+			return ""
+		}
+		if total > 0 && fileType != "flac" {
+			return fmt.Sprintf("%d/%d", number, total)
+		}
+		return strconv.Itoa(number)
+	}
+
+	set(taglib.Title, metadatas.Title)
+	set(taglib.Artist, metadatas.Artist)
+	set(taglib.AlbumArtist, metadatas.AlbumArtist)
+	set(taglib.Album, metadatas.Album)
+	set(taglib.Date, metadatas.Date)
+	set(taglib.TrackNumber, position(metadatas.TrackNumber, metadatas.TrackTotal))
+	set(taglib.DiscNumber, position(metadatas.DiscNumber, metadatas.DiscTotal))
+	set(taglib.Genre, metadatas.Genre)
+	set(taglib.Label, metadatas.Label)
+	set(taglib.ISRC, metadatas.ISRC)
+	set(taglib.Barcode, metadatas.UPC)
+	set(taglib.Copyright, metadatas.Copyright)
+	set(taglib.Composer, metadatas.Composer)
+
+	if fileType == "flac" {
+		if metadatas.TrackTotal > 0 {
+			set("TRACKTOTAL", strconv.Itoa(metadatas.TrackTotal))
+		}
+		if metadatas.DiscTotal > 0 {
+			set("DISCTOTAL", strconv.Itoa(metadatas.DiscTotal))
+		}
 	}
 
-	return res, nil
+	if metadatas.Explicit {
+		set("ITUNESADVISORY", "1")
+	}
+
+	if metadatas.TrackId != 0 {
+		set(TrackIdTag, strconv.Itoa(int(metadatas.TrackId)))
+	}
+	set(AlbumIdTag, metadatas.AlbumId)
+
+	return tags
 }
 
-func _addMetadata(targetFile string, metadatas Metadatas) error {
-	res, err := _request(metadatas.Cover, false, []QueryParams{})
+func (c *Client) fetchCover(ctx context.Context, coverUrl string) ([]byte, error) {
+	res, err := c._request(ctx, coverUrl, false, []QueryParams{})
 
 	if err != nil {
-		return fmt.Errorf("can't download cover")
+		return nil, fmt.Errorf("can't download cover")
 	}
 
 	defer res.Body.Close()
 
 	coverBytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("can't read cover: %w", err)
+	}
+
+	return coverBytes, nil
+}
+
+// _addMetadata tags targetFile, tracks without cover are tagged without
+// picture.
+func (c *Client) _addMetadata(ctx context.Context, targetFile string, fileType string, metadatas Metadatas) error {
+	var coverBytes []byte
+	var err error
+
+	if metadatas.Cover != "" {
+		coverBytes, err = c.fetchCover(ctx, metadatas.Cover)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = taglib.WriteTags(targetFile, map[string][]string{
-		taglib.Title:  {metadatas.Title},
-		taglib.Artist: {metadatas.Artist},
-		taglib.Album:  {metadatas.Album},
-		taglib.Date:   {metadatas.Date},
-	}, 0)
+	err = taglib.WriteTags(targetFile, metadatas.tags(fileType), 0)
 
 	if err != nil {
 		return fmt.Errorf("unable to write metadata to track")
@@ -177,7 +336,11 @@ func _addMetadata(targetFile string, metadatas Metadatas) error {
 	return nil
 }
 
-func Login(email string, password string) error {
+func (c *Client) Login(email string, password string) error {
+	return c.LoginContext(context.Background(), email, password)
+}
+
+func (c *Client) LoginContext(ctx context.Context, email string, password string) error {
 	if email == "" || password == "" {
 		return fmt.Errorf("invalid email or password")
 	}
@@ -195,127 +358,55 @@ func Login(email string, password string) error {
 		return fmt.Errorf("cannot encode login body")
 	}
 
-	res, err := http.Post(
-		fmt.Sprintf("%s/%s", config.GetEndpoint(), "api/auth/login"),
-		"application/json",
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("%s/%s", c.Endpoint, "api/auth/login"),
 		bytes.NewBuffer(out),
 	)
-
-	if res.StatusCode == 401 {
-		return fmt.Errorf("invalid credentials")
-	}
-
-	if res.StatusCode == 200 {
-		var token = ""
-		for _, cook := range res.Cookies() {
-			if cook.Name == "session" {
-				token = cook.Value
-			}
-		}
-
-		if token == "" {
-			return fmt.Errorf("unable to get token")
-		}
-
-		path := filepath.Join(".token")
-		err := os.WriteFile(path, []byte(token), 0644)
-
-		if err != nil {
-			return fmt.Errorf("unable to write .token file")
-		}
-
-		url, _ := url.Parse(config.GetEndpoint())
-		client.Jar.SetCookies(url, []*http.Cookie{
-			{
-				Name:  "session",
-				Value: token,
-			},
-		})
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
 
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("error while making request: %w", err)
 	}
+	defer res.Body.Close()
 
-	return nil
-}
-
-func Search(query string, queryType string) (*SearchResults, error) {
-	if query == "" {
-		return nil, fmt.Errorf("you must provide a valid query parameter")
-	}
-
-	if queryType != "album" && queryType != "track" && queryType != "artist" {
-		return nil, fmt.Errorf("you must provide a queryType of either type `track`, `album` or `artist`")
+	if res.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("invalid credentials: %w", ErrUnauthorized)
 	}
 
-	res, err := _request("api/search", true, []QueryParams{
-		{Name: "q", Value: query},
-		{Name: "type", Value: queryType},
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("search endpoint failed: %s", err)
+	if res.StatusCode != http.StatusOK {
+		return &HTTPError{Status: res.StatusCode, URL: req.URL.String()}
 	}
-	defer res.Body.Close()
-
-	var searchResponse SearchResults
-
-	switch queryType {
-	case "album":
-		var albums AlbumsResults
-		err = json.NewDecoder(res.Body).Decode(&albums)
 
-		if err != nil {
-			return nil, fmt.Errorf("cannot decode response: %w", err)
+	if res.StatusCode == 200 {
+		var cookie *http.Cookie
+		for _, cook := range res.Cookies() {
+			if cook.Name == "session" {
+				cookie = cook
+			}
 		}
 
-		searchResponse.Albums = albums
-	case "track":
-		var response TrackResults
-		err = json.NewDecoder(res.Body).Decode(&response)
-
-		if err != nil {
-			return nil, fmt.Errorf("cannot decode response: %w", err)
+		if cookie == nil || cookie.Value == "" {
+			return fmt.Errorf("unable to get token")
 		}
 
-		searchResponse.Tracks = response
-	case "artist":
-		artists := make(map[float64]Artist)
-		rawJSON := make(map[string]any)
-
-		err = json.NewDecoder(res.Body).Decode(&rawJSON)
+		err := c.saveSession(StoredSession{
+			Endpoint: c.Endpoint,
+			Token:    cookie.Value,
+			Expires:  sessionExpiry(cookie),
+		})
 
 		if err != nil {
-			return nil, fmt.Errorf("cannot decode response: %w", err)
+			return err
 		}
 
-		var tracks = rawJSON["tracks"].([]any)
-
-		for _, t := range tracks {
-			t, ok := t.(map[string]any)
-			if !ok {
-				return nil, fmt.Errorf("track item is not an object")
-			}
-			var artistId float64 = t["artistId"].(float64)
-			_, ok = artists[artistId]
-			if artistId != 0 && !ok {
-				id := ID(artistId)
-				artists[artistId] = Artist{
-					Id:   id,
-					Name: t["artist"].(string),
-				}
-			}
-		}
-
-		artistsSlice := make([]Artist, 0, len(artists))
-		for _, artist := range artists {
-			artistsSlice = append(artistsSlice, artist)
-		}
-		searchResponse.Artists = ArtistResults{
-			Items: artistsSlice,
-		}
+		c.SetSession(cookie.Value)
 	}
 
-	return &searchResponse, nil
+	return nil
 }
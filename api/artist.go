@@ -1,19 +1,25 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"godab/config"
-	"os"
+	"godab/playlist"
+	"path/filepath"
+	"sync"
 
 	"github.com/jedib0t/go-pretty/v6/progress"
 )
 
 type Artist struct {
-	Id          ID     `json:"id"`
-	Name        string `json:"name"`
-	AlbumsCount int    `json:"albumsCount"`
-	Albums      []Album
+	Id          ID      `json:"id"`
+	Name        string  `json:"name"`
+	AlbumsCount int     `json:"albumsCount"`
+	Picture     string  `json:"picture,omitempty"`
+	Albums      []Album `json:"albums,omitempty"`
+
+	client *Client
 }
 
 type RenderMode int
@@ -31,13 +37,17 @@ type RenderContext struct {
 	Tracker *progress.Tracker
 }
 
-func NewArtist(artistId string) (*Artist, error) {
+func (c *Client) NewArtist(artistId string) (*Artist, error) {
+	return c.NewArtistContext(context.Background(), artistId)
+}
+
+func (c *Client) NewArtistContext(ctx context.Context, artistId string) (*Artist, error) {
 	type Response struct {
 		Artist Artist  `json:"artist"`
 		Albums []Album `json:"albums"`
 	}
 
-	res, err := _request("/api/discography", true, []QueryParams{
+	res, err := c._request(ctx, "api/discography", true, []QueryParams{
 		{Name: "artistId", Value: artistId},
 	})
 
@@ -53,23 +63,21 @@ func NewArtist(artistId string) (*Artist, error) {
 		return nil, fmt.Errorf("failed decoding into struct: %w", err)
 	}
 
-	response.Artist.Albums = response.Albums
-
-	return &response.Artist, nil
-}
+	if response.Artist.Id == 0 && len(response.Albums) == 0 {
+		return nil, fmt.Errorf("artist %s %w", artistId, ErrNotFound)
+	}
 
-func (artist *Artist) downloadArtist(format int, rc RenderContext) error {
-	type Response struct {
-		Artist Artist `json:"artist"`
-		Album  Album  `json:"album"`
+	for i := range response.Albums {
+		response.Albums[i].bind(c)
 	}
 
-	var rootFolder = fmt.Sprintf("%s/%s", config.GetDownloadLocation(), artist.Name)
+	response.Artist.Albums = response.Albums
+	response.Artist.client = c
 
-	if !DirExists(rootFolder) {
-		os.Mkdir(rootFolder, 0755)
-	}
+	return &response.Artist, nil
+}
 
+func (c *Client) downloadArtist(ctx context.Context, artist *Artist, format int, rc RenderContext) error {
 	// bar := progressbar.Default(int64(len(artist.Albums)))
 	trackers := make([]*progress.Tracker, 0)
 	for _, album := range artist.Albums {
@@ -83,47 +91,110 @@ func (artist *Artist) downloadArtist(format int, rc RenderContext) error {
 
 	pw := rc.Pw
 
-	go pw.Render()
+	c.render(pw)
 	pw.AppendTrackers(trackers)
 
-	for idx, album := range artist.Albums {
-		res, err := _request("api/album", true, []QueryParams{
-			{Name: "albumId", Value: album.Id},
-		})
+	// albums share the track slots of the client pool, running a few at
+	// once keeps them busy while an album finishes its last tracks
+	albumItems := make([][]playlist.Item, len(artist.Albums))
+	errs := make([]error, len(artist.Albums))
+	sem := make(chan struct{}, c.albumConcurrency())
+	var wg sync.WaitGroup
 
-		if err != nil {
-			return fmt.Errorf("album api failed: %w", err)
+	for idx, album := range artist.Albums {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
 		}
-
-		var response Response
-		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
-			return fmt.Errorf("failed decoding response: %w", err)
+		if ctx.Err() != nil {
+			break
 		}
 
-		rc.Tracker = trackers[idx]
-		if err = response.Album.downloadAlbum(format, rc); err != nil {
-			return fmt.Errorf("%w", err)
+		wg.Add(1)
+		go func(idx int, album Album, rc RenderContext) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fullAlbum, err := c.NewAlbumContext(ctx, album.Id)
+			if err != nil {
+				errs[idx] = fmt.Errorf("%s: album api failed: %w", album.Title, err)
+				return
+			}
+
+			rc.Tracker = trackers[idx]
+			albumItems[idx], err = c.downloadAlbum(ctx, fullAlbum, format, rc)
+			if err != nil {
+				errs[idx] = fmt.Errorf("%s: %w", album.Title, err)
+			}
+		}(idx, album, rc)
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("download of artist %s interrupted: %w", artist.Name, ctx.Err())
+	}
+
+	var items []playlist.Item
+	for _, albumTracks := range albumItems {
+		items = append(items, albumTracks...)
+	}
+
+	if c.Options.WritePlaylists && len(items) > 0 {
+		paths := make([]string, len(items))
+		for i, item := range items {
+			paths[i] = item.Path
 		}
 
-		// bar.Add(1)
+		location := filepath.Join(commonDir(paths), SanitizeFilename(artist.Name)+".m3u8")
+		if err := playlist.WriteM3U8(location, artist.Name, items); err != nil {
+			return fmt.Errorf("unable to write artist playlist: %w", err)
+		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func (artist *Artist) Download(format int) error {
+	return artist.DownloadContext(context.Background(), format)
+}
+
+func (artist *Artist) DownloadContext(ctx context.Context, format int) error {
+	return clientOrDefault(artist.client).DownloadArtistContext(ctx, artist, format)
+}
+
+func (c *Client) DownloadArtist(artist *Artist, format int) error {
+	return c.DownloadArtistContext(context.Background(), artist, format)
+}
+
+func (c *Client) DownloadArtistContext(ctx context.Context, artist *Artist, format int) error {
 	if len(artist.Albums) == 0 {
 		return fmt.Errorf("artist %d has no albums", artist.Id)
 	}
 
+	albums := c.Options.Discography.Apply(artist.Albums)
+	if len(albums) == 0 {
+		return fmt.Errorf("none of the %d albums of artist %s match the filters", len(artist.Albums), artist.Name)
+	}
+
+	if skipped := len(artist.Albums) - len(albums); skipped > 0 {
+		PrintColor(COLOR_BLUE, "Skipping %d of %d albums filtered out or duplicate editions", skipped, len(artist.Albums))
+	}
+
+	filtered := *artist
+	filtered.Albums = albums
+	artist = &filtered
+
 	pw := InitProgress()
 	rc := RenderContext{
 		Pw:   pw,
 		Mode: ModeArtistDownload,
 	}
 
+	defer StopProgress(pw)
+
 	PrintColor(COLOR_GREEN, "Starting download for artist %s\n", artist.Name)
-	err := artist.downloadArtist(format, rc)
+	err := c.downloadArtist(ctx, artist, format, rc)
 
 	if err != nil {
 		return fmt.Errorf("%w", err)
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"crypto/tls"
+	"godab/config"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36"
+
+type Options struct {
+	DownloadLocation string
+
+	// AlbumTemplate and TrackTemplate lay out downloaded files, see Template.
+	AlbumTemplate string
+	TrackTemplate string
+
+	// Existing decides what happens to tracks already on disk, Verify only
+	// keeps them when they look complete.
+	Existing ExistingPolicy
+	Verify   bool
+
+	// Concurrency is the number of tracks downloaded at once, shared by
+	// every album and artist downloaded by the client. AlbumConcurrency is
+	// the number of albums of an artist downloaded at once and
+	// HostConcurrency bounds the transfers from a single host, 0 leaving
+	// them unbounded.
+	Concurrency      int
+	AlbumConcurrency int
+	HostConcurrency  int
+
+	// RequestsPerSecond limits the API calls of the client and the clients
+	// made by WithOptions, allowing bursts of RequestBurst calls. 0 doesn't
+	// limit them.
+	RequestsPerSecond float64
+	RequestBurst      int
+
+	// Fallback lists the quality tiers tried in order when a track isn't
+	// available in the one asked for.
+	Fallback []int
+
+	// WritePlaylists writes a M3U8 playlist next to every downloaded album
+	// and one covering the whole discography of downloaded artists.
+	WritePlaylists bool
+
+	// Discography picks the albums downloaded with artists.
+	Discography DiscographyFilter
+
+	// HideProgress turns progress bars off, for instance when several
+	// downloads share the terminal.
+	HideProgress bool
+
+	// OnTrackResult is called once per track when its download finished or
+	// was given up on, possibly from several goroutines at once.
+	OnTrackResult func(TrackResult)
+
+	// OnTrackEvent follows every step of track downloads, see TrackEvent.
+	// It is called from several goroutines at once too.
+	OnTrackEvent func(TrackEvent)
+}
+
+type TrackResult struct {
+	Track    Track
+	Location string
+	// Quality is the quality tier delivered, which differs from the one
+	// asked for when a fallback was used.
+	Quality int
+	Skipped bool
+	Err     error
+}
+
+// Client holds everything needed to talk to a dabmusic instance. Albums,
+// tracks and artists returned by a client remember it, so their Download
+// methods go through the same session and endpoint.
+type Client struct {
+	Endpoint   string
+	HTTPClient *http.Client
+	UserAgent  string
+	Options    Options
+
+	session   string
+	sessionMu sync.RWMutex
+
+	pool       *workerPool
+	limiter    *rateLimiter
+	auth       *reauth
+	sharedOnce sync.Once
+}
+
+// DefaultClient is the client used by the package level helpers and the CLI.
+var DefaultClient = newDefaultClient()
+
+func newDefaultClient() *Client {
+	return NewClient(config.GetEndpoint(), Options{
+		DownloadLocation: config.GetDownloadLocation(),
+		AlbumTemplate:    config.GetAlbumTemplate(),
+		TrackTemplate:    config.GetTrackTemplate(),
+		Concurrency:      config.GetConcurrency(),
+		AlbumConcurrency: config.GetAlbumConcurrency(),
+		HostConcurrency:  config.GetHostConcurrency(),
+
+		RequestsPerSecond: config.GetRateLimit(),
+		RequestBurst:      config.GetRateBurst(),
+	})
+}
+
+// ConfigureDefaultClient builds DefaultClient again, to be called once the
+// config file has been loaded.
+func ConfigureDefaultClient() {
+	DefaultClient = newDefaultClient()
+}
+
+func NewClient(endpoint string, options Options) *Client {
+	return &Client{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		HTTPClient: newHTTPClient(),
+		UserAgent:  DefaultUserAgent,
+		Options:    options,
+	}
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS13,
+			},
+			IdleConnTimeout:       config.GetIdleConnTimeout(),
+			TLSHandshakeTimeout:   config.GetTLSHandshakeTimeout(),
+			ExpectContinueTimeout: config.GetExpectContinueTimeout(),
+		},
+		Timeout: config.GetTimeout(),
+	}
+}
+
+// WithOptions returns a client sharing the endpoint, session and HTTP client
+// of c with other options.
+func (c *Client) WithOptions(options Options) *Client {
+	return &Client{
+		Endpoint:   c.Endpoint,
+		HTTPClient: c.HTTPClient,
+		UserAgent:  c.UserAgent,
+		Options:    options,
+		session:    c.Session(),
+		pool:       c.workers(),
+		limiter:    c.rateLimiter(),
+		auth:       c.auth,
+	}
+}
+
+func clientOrDefault(c *Client) *Client {
+	if c == nil {
+		return DefaultClient
+	}
+	return c
+}
+
+func (c *Client) Session() string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+	return c.session
+}
+
+func (c *Client) SetSession(token string) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.session = token
+}
+
+func (c *Client) emit(result TrackResult) {
+	if c.Options.OnTrackResult != nil {
+		c.Options.OnTrackResult(result)
+	}
+
+	switch {
+	case result.Err != nil:
+		c.emitEvent(EventFailed, &result.Track, result.Location, func(event *TrackEvent) {
+			event.Error = result.Err.Error()
+		})
+	case result.Skipped:
+		c.emitEvent(EventSkipped, &result.Track, result.Location, nil)
+	default:
+		c.emitEvent(EventDone, &result.Track, result.Location, func(event *TrackEvent) {
+			event.Quality = result.Quality
+		})
+	}
+}
+
+func (c *Client) downloadLocation() string {
+	if c.Options.DownloadLocation == "" {
+		return "."
+	}
+	return c.Options.DownloadLocation
+}
+
+func (c *Client) concurrency() int {
+	if c.Options.Concurrency <= 0 {
+		return 3
+	}
+	return c.Options.Concurrency
+}
+
+func (c *Client) rateLimiter() *rateLimiter {
+	c.initShared()
+	return c.limiter
+}
+
+func (c *Client) albumConcurrency() int {
+	if c.Options.AlbumConcurrency <= 0 {
+		return 2
+	}
+	return c.Options.AlbumConcurrency
+}
+
+func (c *Client) albumTemplate() string {
+	if c.Options.AlbumTemplate == "" {
+		return DefaultAlbumTemplate
+	}
+	return c.Options.AlbumTemplate
+}
+
+func (c *Client) trackTemplate() string {
+	if c.Options.TrackTemplate == "" {
+		return DefaultTrackTemplate
+	}
+	return c.Options.TrackTemplate
+}
+
+func LoadCookies() (bool, error) {
+	return DefaultClient.LoadCookies()
+}
+
+func Logout() error {
+	return DefaultClient.Logout()
+}
+
+func WhoAmI() (*User, error) {
+	return DefaultClient.WhoAmI()
+}
+
+func Login(email string, password string) error {
+	return DefaultClient.Login(email, password)
+}
+
+func Search(query string, queryType string) (*SearchResults, error) {
+	return DefaultClient.Search(query, queryType)
+}
+
+func SearchWith(query string, queryType string, options SearchOptions) (*SearchResults, error) {
+	return DefaultClient.SearchWith(query, queryType, options)
+}
+
+func NewAlbum(albumId string) (*Album, error) {
+	return DefaultClient.NewAlbum(albumId)
+}
+
+func NewTrack(trackId string) (*Track, error) {
+	return DefaultClient.NewTrack(trackId)
+}
+
+func NewArtist(artistId string) (*Artist, error) {
+	return DefaultClient.NewArtist(artistId)
+}
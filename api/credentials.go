@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"godab/config"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrNoCredentials = errors.New("no stored credentials")
+
+// Credentials are the email and password saved with RememberCredentials to
+// log in again once the session expired.
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// reauth serializes logins after a 401 among a client and the clients made
+// by WithOptions, session is the token of the latest one.
+type reauth struct {
+	mu      sync.Mutex
+	session string
+}
+
+func (c *Client) credentialsLocation() string {
+	return filepath.Join(config.GetConfigDir(), "credentials", SanitizeFilename(c.endpointName())+".enc")
+}
+
+// credentialsKeyLocation is the AES key of the credential files. Keeping it
+// apart keeps the passwords out of backups and copies of the credentials
+// folder, anyone reading both files can decrypt them.
+func credentialsKeyLocation() string {
+	return filepath.Join(config.GetConfigDir(), "credentials.key")
+}
+
+func credentialsKey(create bool) ([]byte, error) {
+	location := credentialsKeyLocation()
+
+	key, err := os.ReadFile(location)
+	if err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to read credentials key: %w", err)
+	}
+	if err == nil {
+		return nil, fmt.Errorf("credentials key %s is invalid", location)
+	}
+	if !create {
+		return nil, ErrNoCredentials
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("unable to generate credentials key: %w", err)
+	}
+
+	if err := writePrivateFile(location, key); err != nil {
+		return nil, fmt.Errorf("unable to write credentials key: %w", err)
+	}
+
+	return key, nil
+}
+
+func credentialsCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RememberCredentials saves encrypted credentials for the endpoint of c,
+// used to log in again when the session expires.
+func (c *Client) RememberCredentials(credentials Credentials) error {
+	key, err := credentialsKey(true)
+	if err != nil {
+		return err
+	}
+
+	aead, err := credentialsCipher(key)
+	if err != nil {
+		return fmt.Errorf("unable to set up encryption: %w", err)
+	}
+
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf("unable to encode credentials: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("unable to generate nonce: %w", err)
+	}
+
+	if err := writePrivateFile(c.credentialsLocation(), aead.Seal(nonce, nonce, data, []byte(c.Endpoint))); err != nil {
+		return fmt.Errorf("unable to write credentials file: %w", err)
+	}
+
+	return nil
+}
+
+// StoredCredentials returns the credentials saved for the endpoint of c, or
+// ErrNoCredentials.
+func (c *Client) StoredCredentials() (*Credentials, error) {
+	data, err := os.ReadFile(c.credentialsLocation())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file: %w", err)
+	}
+
+	key, err := credentialsKey(false)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := credentialsCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up encryption: %w", err)
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("credentials file %s is invalid", c.credentialsLocation())
+	}
+
+	// the endpoint is authenticated too, so credentials can't be moved to
+	// another server
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(c.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt credentials file %s: %w", c.credentialsLocation(), err)
+	}
+
+	var credentials Credentials
+	if err := json.Unmarshal(plain, &credentials); err != nil {
+		return nil, fmt.Errorf("unable to decode credentials: %w", err)
+	}
+
+	return &credentials, nil
+}
+
+// ForgetCredentials removes the credentials saved for the endpoint of c.
+func (c *Client) ForgetCredentials() error {
+	err := os.Remove(c.credentialsLocation())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove credentials file: %w", err)
+	}
+	return nil
+}
+
+// Relogin logs in again with the stored credentials.
+func (c *Client) Relogin(ctx context.Context) error {
+	return c.relogin(ctx, c.Session())
+}
+
+// relogin replaces the session rejected by the server, failed, unless a
+// client sharing c already did.
+func (c *Client) relogin(ctx context.Context, failed string) error {
+	c.initShared()
+
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+
+	if c.auth.session != "" && c.auth.session != failed {
+		c.SetSession(c.auth.session)
+		return nil
+	}
+
+	credentials, err := c.StoredCredentials()
+	if err != nil {
+		return err
+	}
+
+	if err := c.LoginContext(ctx, credentials.Email, credentials.Password); err != nil {
+		return fmt.Errorf("unable to log in again: %w", err)
+	}
+
+	c.auth.session = c.Session()
+	return nil
+}
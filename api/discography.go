@@ -0,0 +1,246 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	ReleaseAlbum       = "album"
+	ReleaseEP          = "ep"
+	ReleaseSingle      = "single"
+	ReleaseCompilation = "compilation"
+)
+
+var ReleaseTypes = []string{ReleaseAlbum, ReleaseEP, ReleaseSingle, ReleaseCompilation}
+
+// Edition preferences choosing which album of a set of editions is kept.
+const (
+	PreferExplicit = "explicit"
+	PreferClean    = "clean"
+	PreferQuality  = "quality"
+	PreferLatest   = "latest"
+	PreferOriginal = "original"
+)
+
+var EditionPreferences = []string{PreferExplicit, PreferClean, PreferQuality, PreferLatest, PreferOriginal}
+
+var (
+	compilationTitle = regexp.MustCompile(`(?i)\b(greatest hits|best of|the best|anthology|collection|essentials?|hits)\b`)
+	// editionSuffix is what sets editions of an album apart when it isn't
+	// between brackets, like "Album Deluxe Edition". The qualifier is
+	// required, "Something Special" is a title of its own.
+	editionSuffix = regexp.MustCompile(`(?i)\s+(\d+(st|nd|rd|th)?\s+)?(deluxe|expanded|special|anniversary|super deluxe|remastered|remaster|explicit|clean)(\s+(edition|version|\d{4}))+$`)
+	// editionMarkers matches text made only of edition markers, like
+	// "Deluxe Edition", "2011 Remaster" or "Remastered 2011, Explicit".
+	editionMarkers = regexp.MustCompile(`(?i)^(\s*(\d+(st|nd|rd|th)?\s+)?(super\s+)?(deluxe|expanded|special|anniversary|remastered|remaster|explicit|clean|bonus\s+tracks?)(\s+(edition|version|\d+))*\s*[,;/&+]?)+\s*$`)
+	// editionParts are the bracketed or dash separated parts of a title.
+	editionParts = regexp.MustCompile(`\(([^()]*)\)|\[([^\[\]]*)\]|\s+-\s+(.*)$`)
+)
+
+// DiscographyFilter picks the albums of an artist downloaded. The zero
+// value keeps every album.
+type DiscographyFilter struct {
+	// IncludeTypes keeps only these release types when not empty,
+	// ExcludeTypes drops release types.
+	IncludeTypes []string
+	ExcludeTypes []string
+
+	YearFrom int
+	YearTo   int
+
+	// Dedupe keeps one album of every set of editions (deluxe, remastered,
+	// explicit and clean...) when not empty. Its preferences are tried in
+	// order until one tells the editions apart, the first listed album
+	// wins a tie.
+	Dedupe []string
+}
+
+// ValidateDiscographyFilter checks the release types and edition
+// preferences of filter.
+func ValidateDiscographyFilter(filter DiscographyFilter) error {
+	for _, releaseType := range slices.Concat(filter.IncludeTypes, filter.ExcludeTypes) {
+		if !slices.Contains(ReleaseTypes, releaseType) {
+			return fmt.Errorf("unknown release type %s, use %s", releaseType, strings.Join(ReleaseTypes, ", "))
+		}
+	}
+
+	for _, preference := range filter.Dedupe {
+		if !slices.Contains(EditionPreferences, preference) {
+			return fmt.Errorf("unknown edition preference %s, use %s", preference, strings.Join(EditionPreferences, ", "))
+		}
+	}
+
+	if filter.YearFrom > 0 && filter.YearTo > 0 && filter.YearFrom > filter.YearTo {
+		return fmt.Errorf("year range %d-%d is empty", filter.YearFrom, filter.YearTo)
+	}
+
+	return nil
+}
+
+// Kind is the release type of the album given by the API, or guessed from
+// its artist, title and length when missing.
+func (album *Album) Kind() string {
+	switch releaseType := strings.ToLower(album.ReleaseType); releaseType {
+	case ReleaseAlbum, ReleaseEP, ReleaseSingle, ReleaseCompilation:
+		return releaseType
+	}
+
+	switch {
+	case strings.EqualFold(album.Artist, "Various Artists") || compilationTitle.MatchString(album.Title):
+		return ReleaseCompilation
+	case album.TrackCount > 0 && album.TrackCount <= 3:
+		return ReleaseSingle
+	case album.TrackCount > 0 && album.TrackCount <= 6:
+		return ReleaseEP
+	}
+
+	return ReleaseAlbum
+}
+
+func (filter DiscographyFilter) keep(album *Album) bool {
+	kind := album.Kind()
+
+	if len(filter.IncludeTypes) > 0 && !slices.Contains(filter.IncludeTypes, kind) {
+		return false
+	}
+
+	if slices.Contains(filter.ExcludeTypes, kind) {
+		return false
+	}
+
+	return inYears(album.ReleaseDate, filter.YearFrom, filter.YearTo)
+}
+
+// Apply returns the albums kept by the filter in their original order.
+func (filter DiscographyFilter) Apply(albums []Album) []Album {
+	kept := make([]Album, 0, len(albums))
+	for i := range albums {
+		if filter.keep(&albums[i]) {
+			kept = append(kept, albums[i])
+		}
+	}
+
+	if len(filter.Dedupe) == 0 {
+		return kept
+	}
+
+	// editions are grouped at the position of the first one
+	var keys []string
+	best := make(map[string]int)
+
+	for i := range kept {
+		key := editionKey(&kept[i])
+
+		current, ok := best[key]
+		if !ok {
+			keys = append(keys, key)
+			best[key] = i
+			continue
+		}
+
+		if filter.prefer(&kept[i], &kept[current]) {
+			best[key] = i
+		}
+	}
+
+	deduped := make([]Album, 0, len(keys))
+	for _, key := range keys {
+		deduped = append(deduped, kept[best[key]])
+	}
+
+	return deduped
+}
+
+// prefer reports whether album should replace current as the edition kept.
+func (filter DiscographyFilter) prefer(album *Album, current *Album) bool {
+	for _, preference := range filter.Dedupe {
+		var diff int
+
+		switch preference {
+		case PreferExplicit:
+			diff = compareBool(album.Explicit, current.Explicit)
+		case PreferClean:
+			diff = compareBool(!album.Explicit, !current.Explicit)
+		case PreferQuality:
+			diff = album.AudioQuality.compare(current.AudioQuality)
+		case PreferLatest:
+			diff = strings.Compare(album.ReleaseDate, current.ReleaseDate)
+		case PreferOriginal:
+			diff = strings.Compare(current.ReleaseDate, album.ReleaseDate)
+		}
+
+		if diff != 0 {
+			return diff > 0
+		}
+	}
+
+	return false
+}
+
+func compareBool(a bool, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func (quality AudioQuality) compare(other AudioQuality) int {
+	if quality.MaximumBitDepth != other.MaximumBitDepth {
+		return quality.MaximumBitDepth - other.MaximumBitDepth
+	}
+
+	switch {
+	case quality.MaximumSamplingRate > other.MaximumSamplingRate:
+		return 1
+	case quality.MaximumSamplingRate < other.MaximumSamplingRate:
+		return -1
+	}
+
+	return 0
+}
+
+// editionKey is the same for every edition of an album. Only edition
+// markers are left out, "Alive (Live at Wembley)" and "Alive" or
+// "Hits (Vol. 1)" and "Hits (Vol. 2)" stay apart.
+func editionKey(album *Album) string {
+	title := editionParts.ReplaceAllStringFunc(album.Title, func(part string) string {
+		groups := editionParts.FindStringSubmatch(part)
+		if editionMarkers.MatchString(groups[1] + groups[2] + groups[3]) {
+			return " "
+		}
+		return part
+	})
+
+	title = strings.TrimSpace(title)
+	for {
+		stripped := editionSuffix.ReplaceAllString(title, "")
+		if stripped == title {
+			break
+		}
+		title = stripped
+	}
+
+	return normalize(album.Artist) + "\x00" + simplify(title)
+}
+
+// inYears reports whether date falls between the years from and to, 0
+// leaving a side open.
+func inYears(date string, from int, to int) bool {
+	if from == 0 && to == 0 {
+		return true
+	}
+
+	year, err := strconv.Atoi(year(date))
+	if err != nil {
+		return false
+	}
+
+	return (from == 0 || year >= from) && (to == 0 || year <= to)
+}
@@ -0,0 +1,35 @@
+package api
+
+import "testing"
+
+func TestEditionKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Album", "Album (Deluxe Edition)", true},
+		{"Album", "Album [2011 Remaster]", true},
+		{"Album", "Album - Remastered 2011", true},
+		{"Album", "Album Deluxe Edition", true},
+		{"Album", "Album (20th Anniversary Edition) [Explicit]", true},
+		{"Album", "Album (Remastered 2011, Explicit)", true},
+		{"Alive", "Alive (Live at Wembley)", false},
+		{"Hits (Vol. 1)", "Hits (Vol. 2)", false},
+		{"Album", "Album - Live", false},
+		{"Album", "Album Remastered 2011", true},
+		{"Album", "Album 20th Anniversary Edition", true},
+		{"Something", "Something Special", false},
+		{"Nice", "Nice Clean", false},
+		{"Mixtape", "Mixtape Explicit", false},
+		{"Anniversary", "Anniversary Special", false},
+	}
+
+	for _, test := range tests {
+		a := editionKey(&Album{Title: test.a, Artist: "Artist"})
+		b := editionKey(&Album{Title: test.b, Artist: "Artist"})
+
+		if (a == b) != test.same {
+			t.Errorf("editionKey(%q) == editionKey(%q) is %v, want %v", test.a, test.b, a == b, test.same)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error classes, to be checked with errors.Is. Errors of the client wrap
+// them, so messages keep their details.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrQualityUnavailable = errors.New("quality unavailable")
+)
+
+// HTTPError is an unexpected answer of the server. It matches ErrNotFound,
+// ErrUnauthorized and ErrRateLimited when its status tells so.
+type HTTPError struct {
+	Status int
+	URL    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("request to %s failed with status code: %d %s", e.URL, e.Status, http.StatusText(e.Status))
+}
+
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+	case ErrRateLimited:
+		return e.Status == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// Exit codes of the CLI by error class.
+const (
+	ExitFailure            = 1
+	ExitUsage              = 2
+	ExitUnauthorized       = 3
+	ExitNotFound           = 4
+	ExitRateLimited        = 5
+	ExitQualityUnavailable = 6
+	ExitHTTP               = 7
+	ExitInterrupted        = 130
+)
+
+// ExitCode is the exit code of the CLI failing with err. Joined errors get
+// the code of the first class any of them belongs to.
+func ExitCode(err error) int {
+	var httpErr *HTTPError
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, ErrUnauthorized):
+		return ExitUnauthorized
+	// a missing quality is often answered with a 404, it is the more
+	// precise class
+	case errors.Is(err, ErrQualityUnavailable):
+		return ExitQualityUnavailable
+	case errors.Is(err, ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, ErrRateLimited):
+		return ExitRateLimited
+	case errors.As(err, &httpErr):
+		return ExitHTTP
+	}
+
+	return ExitFailure
+}
@@ -0,0 +1,76 @@
+package api
+
+import "time"
+
+type EventType string
+
+const (
+	EventStarted  EventType = "started"
+	EventProgress EventType = "progress"
+	EventDone     EventType = "done"
+	EventSkipped  EventType = "skipped"
+	EventFailed   EventType = "failed"
+)
+
+// progressEventInterval limits how often progress events are sent for a
+// track.
+const progressEventInterval = 500 * time.Millisecond
+
+// TrackEvent reports the state of a track download to Options.OnTrackEvent,
+// meant for tools driving godab rather than for people.
+type TrackEvent struct {
+	Type     EventType `json:"event"`
+	Time     time.Time `json:"time"`
+	TrackId  ID        `json:"trackId"`
+	Title    string    `json:"title"`
+	Artist   string    `json:"artist"`
+	Album    string    `json:"album"`
+	Location string    `json:"location,omitempty"`
+	Bytes    int64     `json:"bytes,omitempty"`
+	Total    int64     `json:"total,omitempty"`
+	Quality  int       `json:"quality,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (c *Client) emitEvent(eventType EventType, track *Track, location string, apply func(*TrackEvent)) {
+	if c.Options.OnTrackEvent == nil {
+		return
+	}
+
+	event := TrackEvent{
+		Type:     eventType,
+		Time:     time.Now(),
+		TrackId:  track.Id,
+		Title:    track.Title,
+		Artist:   track.Artist,
+		Album:    track.Album,
+		Location: location,
+	}
+
+	if apply != nil {
+		apply(&event)
+	}
+
+	c.Options.OnTrackEvent(event)
+}
+
+// progressEmitter returns the callback of ProgressReader sending progress
+// events, or nil when nobody listens.
+func (c *Client) progressEmitter(track *Track, location string, total int64) func(int64) {
+	if c.Options.OnTrackEvent == nil {
+		return nil
+	}
+
+	var last time.Time
+	return func(bytes int64) {
+		if time.Since(last) < progressEventInterval {
+			return
+		}
+		last = time.Now()
+
+		c.emitEvent(EventProgress, track, location, func(event *TrackEvent) {
+			event.Bytes = bytes
+			event.Total = total
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File types of the downloaded tracks, also their extensions.
+const (
+	FileFLAC = "flac"
+	FileMP3  = "mp3"
+	FileM4A  = "m4a"
+)
+
+var fileTypes = []string{FileFLAC, FileMP3, FileM4A}
+
+var contentTypes = map[string]string{
+	"audio/flac":   FileFLAC,
+	"audio/x-flac": FileFLAC,
+	"audio/mpeg":   FileMP3,
+	"audio/mp3":    FileMP3,
+	"audio/mp4":    FileM4A,
+	"audio/x-m4a":  FileM4A,
+	"video/mp4":    FileM4A,
+}
+
+// sniffFileType tells the file type of a stream from its first bytes, an
+// ID3 tag is skipped since it can precede FLAC as well as MPEG audio.
+func sniffFileType(r io.ReadSeeker) (string, error) {
+	header := make([]byte, 12)
+
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+
+	if bytes.HasPrefix(header, []byte("ID3")) && len(header) >= 10 {
+		// the tag size is a syncsafe integer, 7 bits per byte
+		size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+
+		if _, err := r.Seek(10+size, io.SeekStart); err != nil {
+			return "", err
+		}
+
+		next := make([]byte, 4)
+		if _, err := io.ReadFull(r, next); err == nil && bytes.Equal(next, []byte("fLaC")) {
+			return FileFLAC, nil
+		}
+		return FileMP3, nil
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FileFLAC, nil
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return FileM4A, nil
+	// MPEG audio frame sync, the layer bits set tell it from AAC ADTS
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && header[1]&0x06 != 0:
+		return FileMP3, nil
+	}
+
+	return "", nil
+}
+
+// fileTypeOf returns the file type of the download at location, found from
+// its content or else from the Content-Type the server sent.
+func fileTypeOf(location string, contentType string) (string, error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return "", fmt.Errorf("can't open %s: %w", location, err)
+	}
+	defer file.Close()
+
+	fileType, err := sniffFileType(file)
+	if err != nil {
+		return "", fmt.Errorf("can't read %s: %w", location, err)
+	}
+	if fileType != "" {
+		return fileType, nil
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return contentTypes[mediaType], nil
+	}
+
+	return "", nil
+}
+
+// existingLocation returns the file a previous download of location left,
+// which has another extension when the server delivered another format.
+func existingLocation(location string) string {
+	if FileExists(location) {
+		return location
+	}
+
+	for _, fileType := range fileTypes {
+		if candidate := withExtension(location, fileType); FileExists(candidate) {
+			return candidate
+		}
+	}
+
+	return location
+}
+
+// withExtension replaces the extension of location by the one of fileType.
+func withExtension(location string, fileType string) string {
+	return strings.TrimSuffix(location, filepath.Ext(location)) + "." + fileType
+}
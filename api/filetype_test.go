@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// id3 returns an ID3v2 tag of size bytes of padding followed by data.
+func id3(size int, data []byte) []byte {
+	header := []byte{'I', 'D', '3', 4, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(append(header, make([]byte, size)...), data...)
+}
+
+func TestSniffFileType(t *testing.T) {
+	mpegFrame := []byte{0xff, 0xfb, 0x90, 0x64, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"flac", []byte("fLaC\x00\x00\x00\x22\x10\x00\x10\x00"), FileFLAC},
+		{"mpeg", mpegFrame, FileMP3},
+		{"id3 and mpeg", id3(300, mpegFrame), FileMP3},
+		{"id3 and flac", id3(200, []byte("fLaC\x00\x00\x00\x22")), FileFLAC},
+		{"id3 spanning syncsafe bytes", id3(1000, []byte("fLaC")), FileFLAC},
+		{"ftyp", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x02\x00"), FileM4A},
+		{"adts", []byte{0xff, 0xf1, 0x50, 0x80, 0, 0, 0, 0, 0, 0, 0, 0}, ""},
+		{"ogg", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"), ""},
+		{"short", []byte("fL"), ""},
+		{"empty", nil, ""},
+	}
+
+	for _, test := range tests {
+		got, err := sniffFileType(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: sniffFileType: %v", test.name, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("%s: sniffFileType = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFileTypeOf(t *testing.T) {
+	tests := []struct {
+		data        []byte
+		contentType string
+		want        string
+	}{
+		{[]byte("fLaC\x00\x00\x00\x22"), "audio/mpeg", FileFLAC},
+		{[]byte("unknown bytes"), "audio/mpeg", FileMP3},
+		{[]byte("unknown bytes"), "audio/flac; charset=binary", FileFLAC},
+		{[]byte("unknown bytes"), "audio/mp4", FileM4A},
+		{[]byte("unknown bytes"), "application/octet-stream", ""},
+		{[]byte("unknown bytes"), "", ""},
+	}
+
+	location := filepath.Join(t.TempDir(), "track.part")
+
+	for _, test := range tests {
+		if err := os.WriteFile(location, test.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := fileTypeOf(location, test.contentType)
+		if err != nil {
+			t.Fatalf("fileTypeOf: %v", err)
+		}
+
+		if got != test.want {
+			t.Errorf("fileTypeOf(%q, %q) = %q, want %q", test.data, test.contentType, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"godab/playlist"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Match is the best catalog track found for a playlist entry. Score goes from
+// 0 to 1, an ISRC match scores 1.
+type Match struct {
+	Entry      playlist.Entry
+	Track      *Track
+	Score      float64
+	Candidates int
+}
+
+var (
+	// decorations hides what services add to titles differently, like
+	// "(Remastered 2011)", "[Live]" or "- Radio Edit".
+	decorations = regexp.MustCompile(`\([^)]*\)|\[[^]]*\]| - .*$`)
+	featuring   = regexp.MustCompile(`\b(feat|ft|featuring)\b.*$`)
+)
+
+// normalize lowercases text and drops decorations and punctuation.
+func normalize(text string) string {
+	text = strings.ToLower(text)
+	text = decorations.ReplaceAllString(text, " ")
+	text = featuring.ReplaceAllString(text, " ")
+
+	return simplify(text)
+}
+
+// simplify lowercases text and drops punctuation.
+func simplify(text string) string {
+	text = strings.ToLower(text)
+
+	var out strings.Builder
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			out.WriteRune(r)
+		case r == '&':
+			out.WriteString(" and ")
+		default:
+			out.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(out.String()), " ")
+}
+
+// similarity is 1 minus the Levenshtein distance relative to the longest of
+// the normalized strings.
+func similarity(a string, b string) float64 {
+	ra, rb := []rune(normalize(a)), []rune(normalize(b))
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return 1 - float64(previous[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// artistSimilarity also accepts the entry naming only one of the artists of
+// the track, or the other way around.
+func artistSimilarity(entry string, track string) float64 {
+	best := similarity(entry, track)
+
+	split := func(artists string) []string {
+		return strings.FieldsFunc(artists, func(r rune) bool { return r == ',' || r == ';' || r == '&' || r == '/' })
+	}
+
+	for _, a := range split(entry) {
+		for _, b := range split(track) {
+			best = max(best, 0.9*similarity(a, b))
+		}
+	}
+
+	return best
+}
+
+// scoreMatch weighs title, artist and duration, fields the entry lacks
+// are left out.
+func scoreMatch(entry playlist.Entry, track *Track) float64 {
+	if entry.ISRC != "" && strings.EqualFold(entry.ISRC, track.ISRC) {
+		return 1
+	}
+
+	score := 0.6 * similarity(entry.Title, track.Title)
+	weight := 0.6
+
+	if entry.Artist != "" {
+		score += 0.3 * artistSimilarity(entry.Artist, track.Artist)
+		weight += 0.3
+	}
+
+	if entry.Duration > 0 && track.Duration > 0 {
+		// full score within 3 seconds, none past 30
+		diff := float64(max(entry.Duration-track.Duration, track.Duration-entry.Duration))
+		score += 0.1 * max(0, min(1, 1-(diff-3)/27))
+		weight += 0.1
+	}
+
+	return score / weight
+}
+
+func (c *Client) MatchTrack(entry playlist.Entry) (*Match, error) {
+	return c.MatchTrackContext(context.Background(), entry)
+}
+
+// MatchTrackContext searches the catalog for entry and returns the best
+// scored track. Match.Track is nil when the search found nothing.
+func (c *Client) MatchTrackContext(ctx context.Context, entry playlist.Entry) (*Match, error) {
+	match := &Match{Entry: entry}
+
+	var queries []string
+	if entry.ISRC != "" {
+		queries = append(queries, entry.ISRC)
+	}
+	if entry.Title != "" {
+		queries = append(queries, strings.TrimSpace(entry.Artist+" "+entry.Title))
+	}
+
+	for _, query := range queries {
+		results, err := c.SearchContext(ctx, query, "track")
+		if err != nil {
+			return nil, fmt.Errorf("search for %q failed: %w", query, err)
+		}
+
+		for i := range results.Tracks.Items {
+			track := &results.Tracks.Items[i]
+			match.Candidates++
+
+			if score := scoreMatch(entry, track); match.Track == nil || score > match.Score {
+				match.Track = track
+				match.Score = score
+			}
+		}
+
+		if match.Score == 1 {
+			break
+		}
+	}
+
+	return match, nil
+}
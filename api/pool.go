@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// workerPool bounds the tracks downloaded at once by a client, whatever
+// album or artist they belong to, and the transfers running against a
+// single host. Limits are read on every acquire so changing Options takes
+// effect for the next track. It also keeps two albums rendering to the same
+// paths from writing them at once.
+type workerPool struct {
+	mu      sync.Mutex
+	running int
+	hosts   map[string]int
+	paths   map[string]bool
+	// changed is closed and replaced whenever a slot is released.
+	changed chan struct{}
+}
+
+func newWorkerPool() *workerPool {
+	return &workerPool{
+		hosts:   make(map[string]int),
+		paths:   make(map[string]bool),
+		changed: make(chan struct{}),
+	}
+}
+
+// wait blocks until take succeeds or ctx is done.
+func (p *workerPool) wait(ctx context.Context, take func() bool) error {
+	for {
+		p.mu.Lock()
+		if take() {
+			p.mu.Unlock()
+			return nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *workerPool) release(give func()) {
+	p.mu.Lock()
+	give()
+	close(p.changed)
+	p.changed = make(chan struct{})
+	p.mu.Unlock()
+}
+
+// acquireTrack takes one of the limit track slots.
+func (p *workerPool) acquireTrack(ctx context.Context, limit int) error {
+	return p.wait(ctx, func() bool {
+		if p.running >= limit {
+			return false
+		}
+		p.running++
+		return true
+	})
+}
+
+func (p *workerPool) releaseTrack() {
+	p.release(func() { p.running-- })
+}
+
+// acquireHost takes one of the limit transfer slots of host, a limit of 0
+// doesn't bound it.
+func (p *workerPool) acquireHost(ctx context.Context, host string, limit int) error {
+	return p.wait(ctx, func() bool {
+		if limit > 0 && p.hosts[host] >= limit {
+			return false
+		}
+		p.hosts[host]++
+		return true
+	})
+}
+
+func (p *workerPool) releaseHost(host string) {
+	p.release(func() {
+		p.hosts[host]--
+		if p.hosts[host] <= 0 {
+			delete(p.hosts, host)
+		}
+	})
+}
+
+// acquirePaths claims every path at once, waiting for the downloads
+// holding any of them.
+func (p *workerPool) acquirePaths(ctx context.Context, paths []string) error {
+	return p.wait(ctx, func() bool {
+		for _, path := range paths {
+			if p.paths[path] {
+				return false
+			}
+		}
+		for _, path := range paths {
+			p.paths[path] = true
+		}
+		return true
+	})
+}
+
+func (p *workerPool) releasePaths(paths []string) {
+	p.release(func() {
+		for _, path := range paths {
+			delete(p.paths, path)
+		}
+	})
+}
+
+// workers returns the pool of the client, created on first use and shared
+// with the clients made by WithOptions.
+func (c *Client) workers() *workerPool {
+	c.initShared()
+	return c.pool
+}
+
+// initShared creates what a client shares with the clients made by
+// WithOptions, unless it got them from its parent.
+func (c *Client) initShared() {
+	c.sharedOnce.Do(func() {
+		if c.pool == nil {
+			c.pool = newWorkerPool()
+		}
+		if c.limiter == nil {
+			c.limiter = &rateLimiter{}
+		}
+		if c.auth == nil {
+			c.auth = &reauth{}
+		}
+	})
+}
+
+// withHostSlot runs fn holding a transfer slot of the host of rawUrl.
+func (c *Client) withHostSlot(ctx context.Context, rawUrl string, fn func() error) error {
+	host := rawUrl
+	if u, err := url.Parse(rawUrl); err == nil {
+		host = u.Host
+	}
+
+	pool := c.workers()
+	if err := pool.acquireHost(ctx, host, c.Options.HostConcurrency); err != nil {
+		return err
+	}
+	defer pool.releaseHost(host)
+
+	return fn()
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"strconv"
@@ -33,39 +35,80 @@ func InitProgress() progress.Writer {
 	return pw
 }
 
-func GetTrackersTrackSizes(tracks []Track, format int) []*progress.Tracker {
+// render draws pw in the background unless progress bars are hidden.
+func (c *Client) render(pw progress.Writer) {
+	if !c.Options.HideProgress {
+		go pw.Render()
+	}
+}
+
+func StopProgress(pw progress.Writer) {
+	pw.Stop()
+	for pw.IsRenderInProgress() {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// GetTrackersTrackSizes looks up the sizes a few at once, the lookups share
+// the track slots of the downloads.
+func (c *Client) GetTrackersTrackSizes(ctx context.Context, tracks []Track, format int) []*progress.Tracker {
 	trackers := make([]*progress.Tracker, len(tracks))
 	var wg sync.WaitGroup
+	pool := c.workers()
 
 	for i, t := range tracks {
+		trackers[i] = &progress.Tracker{
+			Message: t.Title,
+			Units:   progress.UnitsBytes,
+		}
+
+		if err := pool.acquireTrack(ctx, c.concurrency()); err != nil {
+			continue
+		}
+
 		wg.Add(1)
 		go func(i int, track Track) {
 			defer wg.Done()
+			defer pool.releaseTrack()
 
-			url, err := track.GetDownloadUrl(format)
+			size, err := c.getTrackSize(ctx, &track, format)
 			if err != nil {
 				return
 			}
 
-			res, err := http.Head(url)
-			if err != nil {
-				return
-			}
-			defer res.Body.Close()
-
-			size, err := strconv.Atoi(res.Header.Get("Content-Length"))
-			if err != nil {
-				return
-			}
-
-			trackers[i] = &progress.Tracker{
-				Message: track.Title,
-				Total:   int64(size),
-				Units:   progress.UnitsBytes,
-			}
+			trackers[i].UpdateTotal(size)
 		}(i, t)
 	}
 
 	wg.Wait()
 	return trackers
 }
+
+func (c *Client) getTrackSize(ctx context.Context, track *Track, format int) (int64, error) {
+	url, _, err := c.streamUrl(ctx, track, format)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("can't create request: %w", err)
+	}
+
+	var res *http.Response
+	err = c.withHostSlot(ctx, url, func() error {
+		res, err = c.do(req)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't fetch track size: %w", err)
+	}
+	defer res.Body.Close()
+
+	size, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("no track size for %s", track.Title)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Quality tiers of the stream endpoint.
+const (
+	QualityMP3      = 5
+	QualityCD       = 6
+	QualityHiRes96  = 7
+	QualityHiRes192 = 27
+	DefaultQuality  = QualityHiRes192
+)
+
+// Qualities lists the quality tiers from the lowest to the highest.
+var Qualities = []int{QualityMP3, QualityCD, QualityHiRes96, QualityHiRes192}
+
+// FormatMap names the quality tiers, flac standing for the highest one.
+var FormatMap = map[string]int{
+	"mp3":       QualityMP3,
+	"cd":        QualityCD,
+	"hires":     QualityHiRes96,
+	"hires-96":  QualityHiRes96,
+	"hires-192": QualityHiRes192,
+	"flac":      QualityHiRes192,
+}
+
+var qualityNames = map[int]string{
+	QualityMP3:      "MP3 320kbps",
+	QualityCD:       "CD 16bit/44.1kHz",
+	QualityHiRes96:  "Hi-Res 24bit/96kHz",
+	QualityHiRes192: "Hi-Res 24bit/192kHz",
+}
+
+// QualityName describes a quality tier.
+func QualityName(quality int) string {
+	if name, ok := qualityNames[quality]; ok {
+		return name
+	}
+	return fmt.Sprintf("quality %d", quality)
+}
+
+// ParseQuality reads a quality tier given by name (mp3, cd, hires-96,
+// hires-192, flac) or by number (5, 6, 7, 27).
+func ParseQuality(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if quality, ok := FormatMap[value]; ok {
+		return quality, nil
+	}
+
+	if quality, err := strconv.Atoi(value); err == nil && slices.Contains(Qualities, quality) {
+		return quality, nil
+	}
+
+	return 0, fmt.Errorf("unknown format %q, use mp3, cd, hires-96, hires-192, flac or one of 5, 6, 7, 27", value)
+}
+
+// ParseQualities reads a comma separated list of quality tiers, tried in
+// order, like "27,7,6,5".
+func ParseQualities(value string) ([]int, error) {
+	var qualities []int
+
+	for part := range strings.SplitSeq(value, ",") {
+		quality, err := ParseQuality(part)
+		if err != nil {
+			return nil, err
+		}
+
+		if slices.Contains(qualities, quality) {
+			return nil, fmt.Errorf("format %q is listed twice", strings.TrimSpace(part))
+		}
+		qualities = append(qualities, quality)
+	}
+
+	return qualities, nil
+}
+
+// qualityExtension is the extension of the files usually delivered in
+// quality, the downloaded bytes tell the actual one.
+func qualityExtension(quality int) string {
+	if quality == QualityMP3 {
+		return FileMP3
+	}
+	return FileFLAC
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxRequestRetries is the number of times a request is sent again
+	// after a rate limited or unavailable answer or a temporary network
+	// error.
+	maxRequestRetries = 4
+	minBackoff        = 500 * time.Millisecond
+	maxBackoff        = 30 * time.Second
+)
+
+// rateLimiter is a token bucket refilled with rate tokens per second up to
+// burst tokens. A Retry-After answer pauses it for every request.
+type rateLimiter struct {
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// wait blocks until a request can be sent, a rate of 0 or less doesn't
+// limit them.
+func (l *rateLimiter) wait(ctx context.Context, rate float64, burst int) error {
+	burst = max(burst, 1)
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+
+		var delay time.Duration
+		switch {
+		case now.Before(l.pausedUntil):
+			delay = l.pausedUntil.Sub(now)
+		case rate <= 0:
+			l.mu.Unlock()
+			return nil
+		default:
+			if l.last.IsZero() {
+				l.tokens = float64(burst)
+			} else {
+				l.tokens = min(float64(burst), l.tokens+now.Sub(l.last).Seconds()*rate)
+			}
+			l.last = now
+
+			if l.tokens >= 1 {
+				l.tokens--
+				l.mu.Unlock()
+				return nil
+			}
+
+			delay = time.Duration((1 - l.tokens) / rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// pause holds every request back until the given time.
+func (l *rateLimiter) pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// retryable reports whether a request answered with status is worth sending
+// again.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// temporaryError reports whether a request failing with err can succeed
+// when sent again, unlike a malformed URL or a refused certificate.
+func temporaryError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// transferError is a temporary failure of a file transfer. Requests to the
+// API are retried on their own, transfers are resumed by the retries of
+// downloadAlbum.
+type transferError struct {
+	err error
+}
+
+func (e *transferError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transferError) Unwrap() error {
+	return e.err
+}
+
+// retryAfter reads the Retry-After header, given in seconds or as a date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(date)), true
+	}
+
+	return 0, false
+}
+
+// backoff is the delay before retry attempt, doubling every attempt with
+// jitter so that parallel requests don't come back all at once.
+func backoff(attempt int) time.Duration {
+	delay := min(maxBackoff, minBackoff<<attempt)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleep waits for d unless ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// partState is stored next to a .part file so an interrupted download can
+// only be resumed against the very same remote file. Complete parts only
+// miss their tags, which change their size.
+type partState struct {
+	ETag        string `json:"etag"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType,omitempty"`
+	Complete    bool   `json:"complete,omitempty"`
+}
+
+var errRemoteChanged = errors.New("remote file changed since the partial download")
+
+func partLocation(location string) string {
+	return location + ".part"
+}
+
+func partStateLocation(location string) string {
+	return location + ".part.json"
+}
+
+// loadPartState returns the saved state of a partial download and how many
+// bytes of it are already on disk. Unusable leftovers are removed and
+// reported as an empty state.
+func loadPartState(location string) (partState, int64) {
+	var state partState
+
+	info, err := os.Stat(partLocation(location))
+	if err != nil {
+		removePart(location)
+		return partState{}, 0
+	}
+
+	data, err := os.ReadFile(partStateLocation(location))
+	if err != nil || json.Unmarshal(data, &state) != nil || state.Size <= 0 {
+		removePart(location)
+		return partState{}, 0
+	}
+
+	if state.Complete {
+		return state, state.Size
+	}
+
+	if info.Size() > state.Size {
+		removePart(location)
+		return partState{}, 0
+	}
+
+	return state, info.Size()
+}
+
+func savePartState(location string, state partState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("cannot encode partial download state: %w", err)
+	}
+
+	return os.WriteFile(partStateLocation(location), data, 0644)
+}
+
+func removePart(location string) {
+	os.Remove(partLocation(location))
+	os.Remove(partStateLocation(location))
+}
+
+// parseContentRange parses a "bytes start-end/total" header.
+func parseContentRange(header string) (start int64, total int64, ok bool) {
+	var end int64
+	_, err := fmt.Sscanf(header, "bytes %d-%d/%d", &start, &end, &total)
+	return start, total, err == nil
+}
+
+func hasPartFiles(dir string) bool {
+	found := false
+
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && strings.HasSuffix(entry.Name(), ".part") {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+
+	return found
+}
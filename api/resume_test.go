@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header string
+		start  int64
+		total  int64
+		ok     bool
+	}{
+		{"bytes 0-99/100", 0, 100, true},
+		{"bytes 512-1172904/1172905", 512, 1172905, true},
+		{"bytes 100-199/*", 0, 0, false},
+		{"bytes */100", 0, 0, false},
+		{"items 0-9/10", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		start, total, ok := parseContentRange(test.header)
+		if ok != test.ok || ok && (start != test.start || total != test.total) {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v, want %d, %d, %v", test.header, start, total, ok, test.start, test.total, test.ok)
+		}
+	}
+}
+
+func TestLoadPartState(t *testing.T) {
+	tests := []struct {
+		name   string
+		part   string
+		state  *partState
+		offset int64
+		kept   bool
+	}{
+		{"resumable", "12345", &partState{ETag: `"abc"`, Size: 10}, 5, true},
+		{"without state", "12345", nil, 0, false},
+		{"larger than the file", "12345678901", &partState{Size: 10}, 0, false},
+		{"complete and tagged", "12345678901234", &partState{Size: 10, Complete: true}, 10, true},
+	}
+
+	for _, test := range tests {
+		location := filepath.Join(t.TempDir(), "track.flac")
+
+		if err := os.WriteFile(partLocation(location), []byte(test.part), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if test.state != nil {
+			if err := savePartState(location, *test.state); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		state, offset := loadPartState(location)
+
+		if offset != test.offset {
+			t.Errorf("%s: offset = %d, want %d", test.name, offset, test.offset)
+		}
+		if test.kept && state != *test.state {
+			t.Errorf("%s: state = %+v, want %+v", test.name, state, *test.state)
+		}
+		if FileExists(partLocation(location)) != test.kept {
+			t.Errorf("%s: part kept = %v, want %v", test.name, !test.kept, test.kept)
+		}
+	}
+}
+
+func TestResumeRemoteChanged(t *testing.T) {
+	file := testFLAC()
+	var gets atomic.Int32
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/api/stream", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"url": server.URL + "/file"})
+	})
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		gets.Add(1)
+
+		// a range of another version of the file
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 5-999/1000")
+			w.WriteHeader(http.StatusPartialContent)
+			return
+		}
+
+		http.ServeContent(w, r, "track.flac", time.Unix(0, 0), bytes.NewReader(file))
+	})
+
+	c := NewClient(server.URL, Options{HideProgress: true})
+	location := filepath.Join(t.TempDir(), "track.flac")
+
+	if err := os.WriteFile(partLocation(location), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := savePartState(location, partState{Size: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	track := &Track{Id: 1, Title: "Song"}
+	location, _, err := c.downloadTrack(context.Background(), track, location, QualityCD, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gets.Load() != 2 {
+		t.Errorf("%d requests, want the resume and a full download", gets.Load())
+	}
+	if !FileExists(location) || FileExists(partLocation(location)) {
+		t.Errorf("%s wasn't downloaded again", location)
+	}
+}
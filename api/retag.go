@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.senan.xyz/taglib"
+)
+
+const coverTag = "COVER"
+
+var ErrNoTrackId = fmt.Errorf("file has no %s tag", TrackIdTag)
+
+type TagChange struct {
+	Key string
+	Old []string
+	New []string
+}
+
+// Retagger rewrites tags and cover art of previously downloaded files with
+// fresh metadata. Albums are cached so a whole library only fetches each of
+// them once.
+type Retagger struct {
+	DryRun bool
+
+	client *Client
+	mu     sync.Mutex
+	albums map[string]*Album
+	covers map[string][]byte
+}
+
+func (c *Client) NewRetagger(dryRun bool) *Retagger {
+	return &Retagger{
+		DryRun: dryRun,
+		client: c,
+		albums: make(map[string]*Album),
+		covers: make(map[string][]byte),
+	}
+}
+
+// RetagContext refreshes the tags of the file at path and returns what
+// changed, or would change in dry run mode.
+func (r *Retagger) RetagContext(ctx context.Context, path string) ([]TagChange, error) {
+	oldTags, err := taglib.ReadTags(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read tags: %w", err)
+	}
+
+	if len(oldTags[TrackIdTag]) == 0 {
+		return nil, ErrNoTrackId
+	}
+
+	trackId, err := strconv.Atoi(oldTags[TrackIdTag][0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s tag %q", TrackIdTag, oldTags[TrackIdTag][0])
+	}
+
+	var albumId string
+	if len(oldTags[AlbumIdTag]) > 0 {
+		albumId = oldTags[AlbumIdTag][0]
+	}
+
+	track, err := r.track(ctx, ID(trackId), albumId)
+	if err != nil {
+		return nil, err
+	}
+
+	fileType := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	newTags := track.Metadatas().tags(fileType)
+
+	var changes []TagChange
+	for key, value := range newTags {
+		if !slices.Equal(oldTags[key], value) {
+			changes = append(changes, TagChange{Key: key, Old: oldTags[key], New: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	cover, err := r.cover(ctx, track.Cover)
+	if err != nil {
+		return nil, err
+	}
+
+	oldCover, _ := taglib.ReadImage(path)
+	coverChanged := len(cover) > 0 && !bytes.Equal(oldCover, cover)
+
+	if coverChanged {
+		changes = append(changes, TagChange{
+			Key: coverTag,
+			Old: []string{fmt.Sprintf("%d bytes", len(oldCover))},
+			New: []string{fmt.Sprintf("%d bytes", len(cover))},
+		})
+	}
+
+	if r.DryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	if err := taglib.WriteTags(path, newTags, 0); err != nil {
+		return nil, fmt.Errorf("unable to write metadata to track: %w", err)
+	}
+
+	if coverChanged {
+		if err := taglib.WriteImage(path, cover); err != nil {
+			return nil, fmt.Errorf("unable to write cover to track: %w", err)
+		}
+	}
+
+	return changes, nil
+}
+
+// track looks the track up in its album, which carries more details than
+// the track search does.
+func (r *Retagger) track(ctx context.Context, trackId ID, albumId string) (*Track, error) {
+	if albumId == "" {
+		track, err := r.client.GetTrackMetadataContext(ctx, trackId)
+		if err != nil {
+			return nil, fmt.Errorf("can't fetch track %d: %w", trackId, err)
+		}
+		albumId = track.AlbumId
+	}
+
+	album, err := r.album(ctx, albumId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range album.Tracks {
+		if album.Tracks[i].Id == trackId {
+			return &album.Tracks[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("track %d of album %s %w", trackId, albumId, ErrNotFound)
+}
+
+func (r *Retagger) album(ctx context.Context, albumId string) (*Album, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if album, ok := r.albums[albumId]; ok {
+		return album, nil
+	}
+
+	album, err := r.client.NewAlbumContext(ctx, albumId)
+	if err != nil {
+		return nil, err
+	}
+
+	r.albums[albumId] = album
+	return album, nil
+}
+
+func (r *Retagger) cover(ctx context.Context, coverUrl string) ([]byte, error) {
+	if coverUrl == "" {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cover, ok := r.covers[coverUrl]; ok {
+		return cover, nil
+	}
+
+	cover, err := r.client.fetchCover(ctx, coverUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	r.covers[coverUrl] = cover
+	return cover, nil
+}
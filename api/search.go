@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxSearchPages stops paging through servers that ignore the offset.
+const maxSearchPages = 50
+
+// SearchOptions pages through results and filters them. Filters are applied
+// on the client and skip what they don't apply to: years and hi-res don't
+// concern artists, the track count only concerns albums.
+type SearchOptions struct {
+	// Limit is the number of results wanted, further pages are fetched
+	// until it is reached. 0 returns the first page only.
+	Limit  int
+	Offset int
+
+	YearFrom  int
+	YearTo    int
+	Artist    string
+	MinTracks int
+	HiResOnly bool
+}
+
+type Pagination struct {
+	Offset  int  `json:"offset"`
+	Limit   int  `json:"limit"`
+	Total   int  `json:"total"`
+	HasMore bool `json:"hasMore"`
+}
+
+func (options SearchOptions) matchYear(date string) bool {
+	return inYears(date, options.YearFrom, options.YearTo)
+}
+
+func (options SearchOptions) matchArtist(artist string) bool {
+	return options.Artist == "" || strings.EqualFold(strings.TrimSpace(artist), strings.TrimSpace(options.Artist))
+}
+
+func (options SearchOptions) matchTrack(track *Track) bool {
+	return options.matchYear(track.ReleaseDate) &&
+		options.matchArtist(track.Artist) &&
+		(!options.HiResOnly || track.AudioQuality.HiRes())
+}
+
+func (options SearchOptions) matchAlbum(album *Album) bool {
+	return options.matchYear(album.ReleaseDate) &&
+		options.matchArtist(album.Artist) &&
+		album.TrackCount >= options.MinTracks &&
+		(!options.HiResOnly || album.AudioQuality.HiRes())
+}
+
+func (options SearchOptions) matchArtistResult(artist *Artist) bool {
+	return options.matchArtist(artist.Name)
+}
+
+func year(date string) string {
+	if len(date) >= 4 {
+		return date[:4]
+	}
+	return ""
+}
+
+func (c *Client) Search(query string, queryType string) (*SearchResults, error) {
+	return c.SearchContext(context.Background(), query, queryType)
+}
+
+func (c *Client) SearchContext(ctx context.Context, query string, queryType string) (*SearchResults, error) {
+	return c.SearchWithContext(ctx, query, queryType, SearchOptions{})
+}
+
+func (c *Client) SearchWith(query string, queryType string, options SearchOptions) (*SearchResults, error) {
+	return c.SearchWithContext(context.Background(), query, queryType, options)
+}
+
+// SearchWithContext searches like SearchContext, fetching pages until
+// options.Limit results passed the filters or the server has no more.
+func (c *Client) SearchWithContext(ctx context.Context, query string, queryType string, options SearchOptions) (*SearchResults, error) {
+	if query == "" {
+		return nil, fmt.Errorf("you must provide a valid query parameter")
+	}
+
+	if queryType != "album" && queryType != "track" && queryType != "artist" {
+		return nil, fmt.Errorf("you must provide a queryType of either type `track`, `album` or `artist`")
+	}
+
+	if options.Limit < 0 || options.Offset < 0 {
+		return nil, fmt.Errorf("limit and offset can't be negative")
+	}
+
+	var results SearchResults
+	offset := options.Offset
+	count := 0
+
+	for page := 0; page < maxSearchPages; page++ {
+		params := []QueryParams{
+			{Name: "q", Value: query},
+			{Name: "type", Value: queryType},
+		}
+		if offset > 0 {
+			params = append(params, QueryParams{Name: "offset", Value: strconv.Itoa(offset)})
+		}
+		if options.Limit > 0 {
+			params = append(params, QueryParams{Name: "limit", Value: strconv.Itoa(options.Limit - count)})
+		}
+
+		pageResults, pagination, size, err := c.searchPage(ctx, queryType, params)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range pageResults.Tracks.Items {
+			if track := &pageResults.Tracks.Items[i]; options.matchTrack(track) && (options.Limit == 0 || count < options.Limit) {
+				results.Tracks.Items = append(results.Tracks.Items, *track)
+				count++
+			}
+		}
+		for i := range pageResults.Albums.Items {
+			if album := &pageResults.Albums.Items[i]; options.matchAlbum(album) && (options.Limit == 0 || count < options.Limit) {
+				results.Albums.Items = append(results.Albums.Items, *album)
+				count++
+			}
+		}
+		for i := range pageResults.Artists.Items {
+			if artist := &pageResults.Artists.Items[i]; options.matchArtistResult(artist) && (options.Limit == 0 || count < options.Limit) {
+				results.Artists.Items = append(results.Artists.Items, *artist)
+				count++
+			}
+		}
+
+		// without pagination details there is no telling whether the
+		// server honors offset, so only the first page is used
+		if options.Limit == 0 || count >= options.Limit || size == 0 || pagination == nil || !pagination.HasMore {
+			break
+		}
+
+		offset += size
+	}
+
+	return &results, nil
+}
+
+// searchPage fetches one page of results, size counts them before any
+// filter.
+func (c *Client) searchPage(ctx context.Context, queryType string, params []QueryParams) (*SearchResults, *Pagination, int, error) {
+	res, err := c._request(ctx, "api/search", true, params)
+
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("search endpoint failed: %s", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("cannot read response: %w", err)
+	}
+
+	var page struct {
+		Pagination *Pagination `json:"pagination"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, nil, 0, fmt.Errorf("cannot decode response: %w", err)
+	}
+
+	var searchResponse SearchResults
+	var size int
+
+	switch queryType {
+	case "album":
+		var albums AlbumsResults
+		err = json.Unmarshal(body, &albums)
+
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("cannot decode response: %w", err)
+		}
+
+		for i := range albums.Items {
+			albums.Items[i].bind(c)
+		}
+
+		searchResponse.Albums = albums
+		size = len(albums.Items)
+	case "track":
+		var response TrackResults
+		err = json.Unmarshal(body, &response)
+
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("cannot decode response: %w", err)
+		}
+
+		for i := range response.Items {
+			response.Items[i].client = c
+		}
+
+		searchResponse.Tracks = response
+		size = len(response.Items)
+	case "artist":
+		var response struct {
+			ArtistResults
+			Tracks []Track `json:"tracks"`
+		}
+		err = json.Unmarshal(body, &response)
+
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("cannot decode response: %w", err)
+		}
+
+		artists := response.Items
+		size = len(artists)
+
+		// servers without artist search answer with tracks, their
+		// artists are the best guess available
+		if response.Items == nil && response.Tracks != nil {
+			artists = artistsOfTracks(response.Tracks)
+			size = len(response.Tracks)
+		}
+
+		// artists without an id can't be opened or downloaded
+		artists = slices.DeleteFunc(artists, func(artist Artist) bool {
+			return artist.Id == 0
+		})
+
+		for i := range artists {
+			artists[i].client = c
+		}
+
+		searchResponse.Artists = ArtistResults{
+			Items: artists,
+		}
+	}
+
+	return &searchResponse, page.Pagination, size, nil
+}
+
+// artistsOfTracks lists the artists of tracks once, in order of appearance.
+func artistsOfTracks(tracks []Track) []Artist {
+	seen := make(map[ID]bool)
+	artists := make([]Artist, 0)
+
+	for _, track := range tracks {
+		if track.ArtistId == 0 || seen[track.ArtistId] {
+			continue
+		}
+		seen[track.ArtistId] = true
+
+		artists = append(artists, Artist{
+			Id:   track.ArtistId,
+			Name: track.Artist,
+		})
+	}
+
+	return artists
+}
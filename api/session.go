@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"godab/config"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrSessionExpired = fmt.Errorf("session expired: %w", ErrUnauthorized)
+
+// StoredSession is the session token saved by Login, one file per endpoint.
+type StoredSession struct {
+	Endpoint string    `json:"endpoint"`
+	Token    string    `json:"token"`
+	Expires  time.Time `json:"expires,omitzero"`
+}
+
+func (s StoredSession) Expired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
+
+type User struct {
+	Id       ID     `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// endpointName is the host of the endpoint, naming its session and
+// credentials files.
+func (c *Client) endpointName() string {
+	if u, err := url.Parse(c.Endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return c.Endpoint
+}
+
+// sessionLocation returns where the session of c.Endpoint is stored, the
+// host keeps sessions of several instances apart.
+func (c *Client) sessionLocation() string {
+	return filepath.Join(config.GetConfigDir(), "sessions", SanitizeFilename(c.endpointName())+".json")
+}
+
+func (c *Client) loadSession() (*StoredSession, error) {
+	data, err := os.ReadFile(c.sessionLocation())
+	if errors.Is(err, os.ErrNotExist) {
+		return c.migrateLegacyToken()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read session file: %w", err)
+	}
+
+	var session StoredSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("unable to decode session file %s: %w", c.sessionLocation(), err)
+	}
+
+	return &session, nil
+}
+
+// legacyTokenLocation is the file older versions wrote the token to, in the
+// working directory.
+const legacyTokenLocation = ".token"
+
+// usesLegacyToken reports whether the .token file can belong to the
+// endpoint of c, older versions only logged in on the default one.
+func (c *Client) usesLegacyToken() bool {
+	return c.Endpoint == strings.TrimSuffix(config.Default("endpoint"), "/")
+}
+
+// migrateLegacyToken moves the token of the .token file into the session
+// file. The .token file goes away, so it can't log in again after a logout.
+func (c *Client) migrateLegacyToken() (*StoredSession, error) {
+	if !c.usesLegacyToken() {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(legacyTokenLocation)
+	if err != nil || len(data) == 0 {
+		return nil, nil
+	}
+
+	session := StoredSession{
+		Endpoint: c.Endpoint,
+		Token:    strings.TrimSpace(string(data)),
+	}
+	session.Expires = tokenExpiry(session.Token)
+
+	if err := c.saveSession(session); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(legacyTokenLocation); err != nil {
+		return nil, fmt.Errorf("unable to remove legacy token file: %w", err)
+	}
+
+	return &session, nil
+}
+
+func (c *Client) saveSession(session StoredSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("unable to encode session: %w", err)
+	}
+
+	if err := writePrivateFile(c.sessionLocation(), data); err != nil {
+		return fmt.Errorf("unable to write session file: %w", err)
+	}
+
+	return nil
+}
+
+// StoredSession returns the session saved for the endpoint of c, or nil when
+// there is none.
+func (c *Client) StoredSession() (*StoredSession, error) {
+	return c.loadSession()
+}
+
+// sessionExpiry prefers the expiry of the cookie and falls back to the one
+// of the token itself.
+func sessionExpiry(cookie *http.Cookie) time.Time {
+	switch {
+	case cookie.MaxAge > 0:
+		return time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		return cookie.Expires
+	}
+
+	return tokenExpiry(cookie.Value)
+}
+
+// tokenExpiry reads the exp claim of JWT tokens, other tokens never expire
+// as far as we know.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
+
+// Logout forgets the session of the endpoint of c.
+func (c *Client) Logout() error {
+	c.SetSession("")
+
+	locations := []string{c.sessionLocation()}
+	if c.usesLegacyToken() {
+		locations = append(locations, legacyTokenLocation)
+	}
+
+	for _, location := range locations {
+		err := os.Remove(location)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("unable to remove session file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) WhoAmI() (*User, error) {
+	return c.WhoAmIContext(context.Background())
+}
+
+// WhoAmIContext returns the user the session belongs to, it fails with
+// ErrSessionExpired once the server no longer accepts it.
+func (c *Client) WhoAmIContext(ctx context.Context) (*User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", c.Endpoint, "api/auth/me"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, ErrSessionExpired
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &HTTPError{Status: res.StatusCode, URL: req.URL.String()}
+	}
+
+	var response struct {
+		User *User `json:"user"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("cannot decode response: %w", err)
+	}
+
+	if response.User == nil {
+		return nil, ErrSessionExpired
+	}
+
+	return response.User, nil
+}
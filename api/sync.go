@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+
+	"go.senan.xyz/taglib"
+)
+
+// ExistingPolicy tells the client what to do with tracks that are already
+// present in the download location.
+type ExistingPolicy int
+
+const (
+	ExistingFail ExistingPolicy = iota
+	ExistingSkip
+	ExistingOverwrite
+)
+
+// skipExisting reports whether the track at location is already downloaded
+// and can be left alone. With Options.Verify only complete files are kept.
+func (c *Client) skipExisting(ctx context.Context, track *Track, location string, format int) bool {
+	if !FileExists(location) || c.Options.Existing == ExistingOverwrite {
+		return false
+	}
+
+	if !c.Options.Verify {
+		return true
+	}
+
+	if err := c.verifyTrack(ctx, track, location, format); err != nil {
+		PrintColor(COLOR_YELLOW, "%s is incomplete (%s), downloading it again", location, err)
+		return false
+	}
+
+	return true
+}
+
+func (c *Client) verifyTrack(ctx context.Context, track *Track, location string, format int) error {
+	info, err := os.Stat(location)
+	if err != nil {
+		return fmt.Errorf("can't stat file: %w", err)
+	}
+
+	// Tagging and cover art change the size slightly, a truncated download
+	// is missing far more than that.
+	if size, err := c.getTrackSize(ctx, track, format); err == nil && info.Size() < size*9/10 {
+		return fmt.Errorf("file has %d of %d bytes", info.Size(), size)
+	}
+
+	tags, err := taglib.ReadTags(location)
+	if err != nil {
+		return fmt.Errorf("can't read tags: %w", err)
+	}
+
+	if title := tags[taglib.Title]; len(title) == 0 || title[0] != track.Title {
+		return fmt.Errorf("title tag doesn't match")
+	}
+
+	properties, err := taglib.ReadProperties(location)
+	if err != nil {
+		return fmt.Errorf("can't read audio properties: %w", err)
+	}
+
+	if track.Duration > 0 && math.Abs(properties.Length.Seconds()-float64(track.Duration)) > 2 {
+		return fmt.Errorf("duration is %s instead of %ds", properties.Length, track.Duration)
+	}
+
+	return nil
+}
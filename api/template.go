@@ -0,0 +1,279 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultAlbumTemplate = "{albumartist}/{album}/[{disc}-]{track:02} - {title}"
+	DefaultTrackTemplate = "{artist}/{title}"
+)
+
+var templateFieldNames = []string{
+	"id",
+	"title",
+	"artist",
+	"album",
+	"albumartist",
+	"date",
+	"year",
+	"track",
+	"tracktotal",
+	"disc",
+	"disctotal",
+	"genre",
+	"label",
+	"isrc",
+	"upc",
+}
+
+// Template renders file paths out of track and album fields.
+//
+//	{field}          value of field
+//	{track:02}       numeric value zero padded to 2 digits
+//	{albumartist|artist|"Unknown"}
+//	                 first non empty field, quoted text is used as is
+//	[ ({year})]      only rendered when every field inside is non empty
+//
+// Only a "/" of the template separates folders, the ones of field values
+// are replaced. Every segment of the result goes through SanitizeFilename.
+type Template struct {
+	nodes []templateNode
+}
+
+type templateNode struct {
+	literal  string
+	fields   []string
+	pad      int
+	optional []templateNode
+}
+
+func ParseTemplate(text string) (*Template, error) {
+	nodes, rest, err := parseTemplateNodes(text, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", text, err)
+	}
+
+	if rest != "" {
+		return nil, fmt.Errorf("invalid template %q: unexpected ]", text)
+	}
+
+	return &Template{nodes: nodes}, nil
+}
+
+func parseTemplateNodes(text string, optional bool) ([]templateNode, string, error) {
+	var nodes []templateNode
+	var literal strings.Builder
+
+	flush := func() {
+		if literal.Len() > 0 {
+			nodes = append(nodes, templateNode{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for len(text) > 0 {
+		switch text[0] {
+		case '{':
+			end := strings.IndexByte(text, '}')
+			if end == -1 {
+				return nil, "", fmt.Errorf("missing }")
+			}
+
+			node, err := parseTemplateField(text[1:end])
+			if err != nil {
+				return nil, "", err
+			}
+
+			flush()
+			nodes = append(nodes, node)
+			text = text[end+1:]
+		case '[':
+			inner, rest, err := parseTemplateNodes(text[1:], true)
+			if err != nil {
+				return nil, "", err
+			}
+
+			if !strings.HasPrefix(rest, "]") {
+				return nil, "", fmt.Errorf("missing ]")
+			}
+
+			flush()
+			nodes = append(nodes, templateNode{optional: inner})
+			text = rest[1:]
+		case ']':
+			if !optional {
+				return nil, "", fmt.Errorf("unexpected ]")
+			}
+
+			flush()
+			return nodes, text, nil
+		case '}':
+			return nil, "", fmt.Errorf("unexpected }")
+		default:
+			literal.WriteByte(text[0])
+			text = text[1:]
+		}
+	}
+
+	flush()
+	return nodes, "", nil
+}
+
+func parseTemplateField(expr string) (templateNode, error) {
+	var node templateNode
+
+	if name, pad, found := strings.Cut(expr, ":"); found {
+		width, err := strconv.Atoi(pad)
+		if err != nil || width <= 0 {
+			return node, fmt.Errorf("invalid padding %q", pad)
+		}
+
+		node.pad = width
+		expr = name
+	}
+
+	for _, field := range strings.Split(expr, "|") {
+		field = strings.TrimSpace(field)
+
+		isQuoted := len(field) >= 2 && strings.HasPrefix(field, `"`) && strings.HasSuffix(field, `"`)
+		if !isQuoted && !slices.Contains(templateFieldNames, field) {
+			return node, fmt.Errorf("unknown field %q", field)
+		}
+
+		node.fields = append(node.fields, field)
+	}
+
+	return node, nil
+}
+
+// Render returns the relative path for fields, without file extension.
+func (t *Template) Render(fields map[string]string) string {
+	rendered, _ := renderTemplateNodes(t.nodes, fields)
+
+	var segments []string
+	for _, segment := range strings.Split(rendered, "/") {
+		if segment = SanitizeFilename(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return filepath.Join(segments...)
+}
+
+var pathSeparators = strings.NewReplacer("/", "_", "\\", "_")
+
+// renderTemplateNodes also reports whether every field it used had a value.
+func renderTemplateNodes(nodes []templateNode, fields map[string]string) (string, bool) {
+	var out strings.Builder
+	complete := true
+
+	for _, node := range nodes {
+		switch {
+		case node.optional != nil:
+			if inner, ok := renderTemplateNodes(node.optional, fields); ok {
+				out.WriteString(inner)
+			}
+		case node.fields != nil:
+			value := ""
+			for _, field := range node.fields {
+				if strings.HasPrefix(field, `"`) {
+					value = strings.Trim(field, `"`)
+				} else {
+					value = pathSeparators.Replace(fields[field])
+				}
+
+				if value != "" {
+					break
+				}
+			}
+
+			if value == "" {
+				complete = false
+			}
+
+			if n, err := strconv.Atoi(value); err == nil && node.pad > 0 {
+				value = fmt.Sprintf("%0*d", node.pad, n)
+			}
+
+			out.WriteString(value)
+		default:
+			out.WriteString(node.literal)
+		}
+	}
+
+	return out.String(), complete
+}
+
+// templateFields collects the values a template can use. album is nil for
+// tracks downloaded on their own. disc is only set on multi disc releases so
+// "[{disc}-]" stays out of single disc ones.
+func templateFields(track *Track, album *Album) map[string]string {
+	fields := map[string]string{
+		"id":          strconv.Itoa(int(track.Id)),
+		"title":       track.Title,
+		"artist":      track.Artist,
+		"album":       track.Album,
+		"albumartist": cmp.Or(track.AlbumArtist, track.Artist),
+		"date":        track.ReleaseDate,
+		"genre":       track.Genre,
+		"label":       track.Label,
+		"isrc":        track.ISRC,
+		"upc":         track.UPC,
+	}
+
+	number := func(field string, value int) {
+		if value > 0 {
+			fields[field] = strconv.Itoa(value)
+		}
+	}
+
+	number("track", track.TrackNumber)
+	number("tracktotal", track.TrackTotal)
+	number("disctotal", track.DiscTotal)
+
+	if track.DiscTotal > 1 {
+		number("disc", track.DiscNumber)
+	}
+
+	if album != nil {
+		fields["album"] = album.Title
+		fields["albumartist"] = album.Artist
+
+		if fields["date"] == "" {
+			fields["date"] = album.ReleaseDate
+		}
+	}
+
+	if len(fields["date"]) >= 4 {
+		fields["year"] = fields["date"][:4]
+	}
+
+	return fields
+}
+
+// commonDir returns the deepest directory containing every path.
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+
+	common := filepath.Dir(paths[0])
+	for _, path := range paths[1:] {
+		dir := filepath.Dir(path)
+		for common != dir && !strings.HasPrefix(dir, common+string(filepath.Separator)) {
+			parent := filepath.Dir(common)
+			if parent == common {
+				return common
+			}
+			common = parent
+		}
+	}
+
+	return common
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{DefaultAlbumTemplate, true},
+		{DefaultTrackTemplate, true},
+		{`{albumartist|artist|"Unknown"}/{title}`, true},
+		{"[{year} - ]{album}", true},
+		{"{track:02} {title}", true},
+		{"{nope}", false},
+		{"{title", false},
+		{"title}", false},
+		{"[{year}", false},
+		{"{year}]", false},
+		{"{track:x}", false},
+		{"{track:0}", false},
+	}
+
+	for _, test := range tests {
+		_, err := ParseTemplate(test.template)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseTemplate(%q) error = %v, want valid %v", test.template, err, test.valid)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	fields := map[string]string{
+		"title":       "Song",
+		"artist":      "Artist",
+		"albumartist": "Album Artist",
+		"album":       "Album",
+		"year":        "2020",
+		"track":       "3",
+	}
+
+	tests := []struct {
+		template string
+		fields   map[string]string
+		want     string
+	}{
+		{DefaultAlbumTemplate, fields, "Album Artist/Album/03 - Song"},
+		{"{albumartist}/{album}/[{disc}-]{track:02} - {title}", map[string]string{"albumartist": "A", "album": "B", "disc": "2", "track": "1", "title": "T"}, "A/B/2-01 - T"},
+		{"[{year} - ]{album}", fields, "2020 - Album"},
+		{"[{year} - ]{album}", map[string]string{"album": "Album"}, "Album"},
+		{`{genre|"Unknown"}/{title}`, fields, "Unknown/Song"},
+		{"{label|artist}", fields, "Artist"},
+		{"{track:03}", fields, "003"},
+		{"{album}//{title}", fields, "Album/Song"},
+		{DefaultAlbumTemplate, map[string]string{"albumartist": "AC/DC", "album": "Back/In Black", "track": "1", "title": `Hells\Bells`}, "AC_DC/Back_In Black/01 - Hells_Bells"},
+		{"{title}", map[string]string{"title": "What?: Yes*"}, "What__ Yes_"},
+		{"{artist}/{title}", map[string]string{"artist": "..", "title": "Song"}, "Song"},
+	}
+
+	for _, test := range tests {
+		template, err := ParseTemplate(test.template)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q): %v", test.template, err)
+		}
+
+		if got := template.Render(test.fields); got != filepath.FromSlash(test.want) {
+			t.Errorf("Render(%q) = %q, want %q", test.template, got, test.want)
+		}
+	}
+}
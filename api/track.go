@@ -1,82 +1,169 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"godab/config"
+	"godab/playlist"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/progress"
 )
 
+// ProgressReader reports the bytes read so far to Tracker and OnProgress,
+// both optional.
 type ProgressReader struct {
-	Reader   io.Reader
-	Tracker  *progress.Tracker
-	Progress int64
+	Reader     io.Reader
+	Tracker    *progress.Tracker
+	OnProgress func(int64)
+	Progress   int64
 }
 
 func (pr *ProgressReader) Read(p []byte) (int, error) {
 	n, err := pr.Reader.Read(p)
 	if n > 0 {
 		pr.Progress += int64(n)
-		pr.Tracker.SetValue(pr.Progress)
+		if pr.Tracker != nil {
+			pr.Tracker.SetValue(pr.Progress)
+		}
+		if pr.OnProgress != nil {
+			pr.OnProgress(pr.Progress)
+		}
 	}
 	return n, err
 }
 
 type Track struct {
-	Id          ID     `json:"id"`
-	Title       string `json:"title"`
-	Artist      string `json:"artist"`
-	Album       string `json:"albumTitle"`
-	Cover       string `json:"albumCover"`
-	ReleaseDate string `json:"releaseDate"`
-	Duration    int    `json:"duration"`
-	TrackNumber int    `json:"-"`
+	Id           ID           `json:"id"`
+	Title        string       `json:"title"`
+	Artist       string       `json:"artist"`
+	ArtistId     ID           `json:"artistId"`
+	AlbumArtist  string       `json:"albumArtist"`
+	Album        string       `json:"albumTitle"`
+	AlbumId      string       `json:"albumId"`
+	Cover        string       `json:"albumCover"`
+	ReleaseDate  string       `json:"releaseDate"`
+	Duration     int          `json:"duration"`
+	TrackNumber  int          `json:"trackNumber"`
+	TrackTotal   int          `json:"trackTotal"`
+	DiscNumber   int          `json:"discNumber"`
+	DiscTotal    int          `json:"discTotal"`
+	Genre        string       `json:"genre"`
+	Label        string       `json:"label"`
+	ISRC         string       `json:"isrc"`
+	UPC          string       `json:"upc"`
+	Copyright    string       `json:"copyright"`
+	Composer     string       `json:"composer"`
+	Explicit     bool         `json:"explicit"`
+	AudioQuality AudioQuality `json:"audioQuality"`
+
+	client *Client
 }
 
-func NewTrack(trackId string) (*Track, error) {
+func (track *Track) UnmarshalJSON(data []byte) error {
+	type plain Track
+
+	aux := struct {
+		*plain
+		Genre           json.RawMessage `json:"genre"`
+		Label           json.RawMessage `json:"label"`
+		Composer        json.RawMessage `json:"composer"`
+		MediaNumber     int             `json:"mediaNumber"`
+		ParentalWarning bool            `json:"parental_warning"`
+	}{plain: (*plain)(track)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	track.Genre = decodeName(aux.Genre)
+	track.Label = decodeName(aux.Label)
+	track.Composer = decodeName(aux.Composer)
+	track.Explicit = track.Explicit || aux.ParentalWarning
+
+	if track.DiscNumber == 0 {
+		track.DiscNumber = aux.MediaNumber
+	}
+
+	return nil
+}
+
+func (c *Client) NewTrack(trackId string) (*Track, error) {
+	return c.NewTrackContext(context.Background(), trackId)
+}
+
+func (c *Client) NewTrackContext(ctx context.Context, trackId string) (*Track, error) {
 	id, err := strconv.Atoi(trackId)
 	if err != nil {
 		return nil, fmt.Errorf("invalid track id")
 	}
 
-	track := &Track{Id: ID(id)}
-
-	metadata, err := track.GetTrackMetadata()
+	metadata, err := c.GetTrackMetadataContext(ctx, ID(id))
 
 	if err != nil {
-		fmt.Print(err)
-		return nil, fmt.Errorf("track not found")
+		return nil, fmt.Errorf("can't fetch track %s: %w", trackId, err)
 	}
 
-	*track = metadata
+	// Search results lack the album only details like track and disc
+	// number, the album has them.
+	if metadata.AlbumId != "" {
+		if album, err := c.NewAlbumContext(ctx, metadata.AlbumId); err == nil {
+			for _, albumTrack := range album.Tracks {
+				if albumTrack.Id == metadata.Id {
+					return &albumTrack, nil
+				}
+			}
+		}
+	}
 
-	return track, nil
+	return &metadata, nil
 }
 
-func (track *Track) TrackProgress(tk *progress.Tracker, res *http.Response, file *os.File) error {
-	pr := &ProgressReader{
-		Reader:  res.Body,
-		Tracker: tk,
+func playlistItem(track *Track, location string) playlist.Item {
+	return playlist.Item{
+		Path:     location,
+		Title:    fmt.Sprintf("%s - %s", track.Artist, track.Title),
+		Duration: track.Duration,
 	}
+}
 
-	buf := make([]byte, 32*1024)
-	_, err := io.CopyBuffer(file, pr, buf)
-
-	if err != nil {
-		return fmt.Errorf("copy error: %w", err)
+func (track *Track) Metadatas() Metadatas {
+	return Metadatas{
+		TrackId:     track.Id,
+		AlbumId:     track.AlbumId,
+		Title:       track.Title,
+		Artist:      track.Artist,
+		AlbumArtist: track.AlbumArtist,
+		Album:       track.Album,
+		Date:        track.ReleaseDate,
+		Cover:       track.Cover,
+		TrackNumber: track.TrackNumber,
+		TrackTotal:  track.TrackTotal,
+		DiscNumber:  track.DiscNumber,
+		DiscTotal:   track.DiscTotal,
+		Genre:       track.Genre,
+		Label:       track.Label,
+		ISRC:        track.ISRC,
+		UPC:         track.UPC,
+		Copyright:   track.Copyright,
+		Composer:    track.Composer,
+		Explicit:    track.Explicit,
 	}
+}
 
-	return nil
+func (c *Client) GetTrackMetadata(id ID) (Track, error) {
+	return c.GetTrackMetadataContext(context.Background(), id)
 }
 
-func (track *Track) GetTrackMetadata() (Track, error) {
-	trackId := strconv.Itoa(int(track.Id))
-	res, err := Search(trackId, "track")
+func (c *Client) GetTrackMetadataContext(ctx context.Context, id ID) (Track, error) {
+	trackId := strconv.Itoa(int(id))
+	res, err := c.SearchContext(ctx, trackId, "track")
 
 	if err != nil {
 		return Track{}, fmt.Errorf("search api failed: %w", err)
@@ -85,7 +172,7 @@ func (track *Track) GetTrackMetadata() (Track, error) {
 	tracks := res.Tracks
 
 	if len(tracks.Items) == 0 {
-		return Track{}, fmt.Errorf("no results found for track id %s", trackId)
+		return Track{}, fmt.Errorf("track %s %w", trackId, ErrNotFound)
 	}
 
 	trackData := tracks.Items[0]
@@ -93,126 +180,269 @@ func (track *Track) GetTrackMetadata() (Track, error) {
 	return trackData, nil
 }
 
-func (track *Track) GetDownloadUrl(format int) (string, error) {
+func (c *Client) GetDownloadUrl(track *Track, format int) (string, error) {
+	return c.GetDownloadUrlContext(context.Background(), track, format)
+}
+
+func (c *Client) GetDownloadUrlContext(ctx context.Context, track *Track, format int) (string, error) {
 	type StreamUrl struct {
 		Url string `json:"url"`
 	}
 
-	res, err := _request("api/stream", true, []QueryParams{
+	res, err := c._request(ctx, "api/stream", true, []QueryParams{
 		{Name: "trackId", Value: strconv.Itoa(int(track.Id))},
 		{Name: "quality", Value: fmt.Sprint(format)},
 	})
+	// the stream endpoint answers 404 for qualities the track isn't
+	// available in
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("track %d: %w (%w)", track.Id, ErrQualityUnavailable, err)
+	}
 	if err != nil {
 		return "", fmt.Errorf("can't get stream URL: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("request failed with status: %d", res.StatusCode)
-	}
-
 	var response StreamUrl
 	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
 		return "", fmt.Errorf("can't decode stream response: %w", err)
 	}
 
+	if response.Url == "" {
+		return "", fmt.Errorf("track %d: %w", track.Id, ErrQualityUnavailable)
+	}
+
 	return response.Url, nil
 }
 
-func (track *Track) downloadTrack(location string, format int, tk *progress.Tracker) error {
-	streamUrl, err := track.GetDownloadUrl(format)
+// streamUrl returns the stream of the first quality the track is
+// available in, format then the fallback ones.
+func (c *Client) streamUrl(ctx context.Context, track *Track, format int) (string, int, error) {
+	var err error
+
+	for _, quality := range append([]int{format}, c.Options.Fallback...) {
+		var streamUrl string
+
+		streamUrl, err = c.GetDownloadUrlContext(ctx, track, quality)
+		if err == nil {
+			return streamUrl, quality, nil
+		}
+		if !errors.Is(err, ErrQualityUnavailable) {
+			break
+		}
+	}
+
+	return "", format, err
+}
+
+// downloadTrack returns where the track was written and the quality
+// delivered, the extension of location follows the format of the file.
+func (c *Client) downloadTrack(ctx context.Context, track *Track, location string, format int, tk *progress.Tracker) (string, int, error) {
+	streamUrl, quality, err := c.streamUrl(ctx, track, format)
+	if err == nil {
+		location = withExtension(location, qualityExtension(quality))
+	}
+
+	c.emitEvent(EventStarted, track, location, nil)
+
+	if err != nil {
+		return location, format, fmt.Errorf("unable to fetch stream url: %w", err)
+	}
+
+	state, offset := loadPartState(location)
+
+	if offset < state.Size || state.Size == 0 {
+		err = c.withHostSlot(ctx, streamUrl, func() error {
+			state, offset, err = c.fetchPart(ctx, track, streamUrl, location, state, offset, tk)
+			if errors.Is(err, errRemoteChanged) {
+				// the part is gone, the whole file is downloaded again
+				state, offset, err = c.fetchPart(ctx, track, streamUrl, location, partState{}, 0, tk)
+			}
+			return err
+		})
+
+		var httpErr *HTTPError
+		if err != nil && ctx.Err() == nil && (temporaryError(err) || errors.As(err, &httpErr) && retryable(httpErr.Status)) {
+			return location, quality, &transferError{err: err}
+		}
+		if err != nil {
+			return location, quality, err
+		}
+	}
+
+	if offset != state.Size {
+		return location, quality, &transferError{err: fmt.Errorf("incomplete download: got %d of %d bytes", offset, state.Size)}
+	}
 
+	// A retry after a tagging failure only tags again.
+	if !state.Complete {
+		state.Complete = true
+		if err := savePartState(location, state); err != nil {
+			return location, quality, fmt.Errorf("can't save partial download state: %w", err)
+		}
+	}
+
+	// The server can deliver another format than the one asked for, an MP3
+	// for a FLAC request, the file is named and tagged after its content.
+	fileType, err := fileTypeOf(partLocation(location), state.ContentType)
 	if err != nil {
-		return fmt.Errorf("unable to fetch stream url: %w", err)
+		return location, quality, err
+	}
+	target := location
+	if fileType != "" {
+		target = withExtension(location, fileType)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, streamUrl, nil)
+	err = c._addMetadata(ctx, partLocation(location), strings.TrimPrefix(filepath.Ext(target), "."), track.Metadatas())
+
 	if err != nil {
-		return fmt.Errorf("can't create request: %w", err)
+		return location, quality, fmt.Errorf("cannot add metadata: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36")
+	if err = os.Rename(partLocation(location), target); err != nil {
+		return location, quality, fmt.Errorf("can't move %s into place: %w", target, err)
+	}
+	os.Remove(partStateLocation(location))
+
+	return target, quality, nil
+}
 
-	res, err := client.Do(req)
+// fetchPart downloads the stream into the .part file of location, resuming
+// from offset when the server still serves the same file. It returns the
+// state of the part file and its new size.
+func (c *Client) fetchPart(ctx context.Context, track *Track, streamUrl string, location string, state partState, offset int64, tk *progress.Tracker) (partState, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamUrl, nil)
 	if err != nil {
-		return fmt.Errorf("download request failed: %w", err)
+		return state, offset, fmt.Errorf("can't create request: %w", err)
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if state.ETag != "" {
+			req.Header.Set("If-Range", state.ETag)
+		}
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %d", res.StatusCode)
+	res, err := c.do(req)
+	if err != nil {
+		return state, offset, fmt.Errorf("download request failed: %w", err)
 	}
+	defer res.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent && offset > 0:
+		start, total, ok := parseContentRange(res.Header.Get("Content-Range"))
+		etag := res.Header.Get("ETag")
+
+		if !ok || start != offset || total != state.Size || (etag != "" && state.ETag != "" && etag != state.ETag) {
+			removePart(location)
+			return partState{}, 0, errRemoteChanged
+		}
+
+		flags |= os.O_APPEND
+	case res.StatusCode == http.StatusOK:
+		offset = 0
+		state = partState{
+			ETag:        res.Header.Get("ETag"),
+			Size:        res.ContentLength,
+			ContentType: res.Header.Get("Content-Type"),
+		}
+		flags |= os.O_TRUNC
 
-	if contentLength := res.ContentLength; contentLength > 0 && tk != nil {
-		tk.Total = contentLength
+		if state.Size > 0 {
+			if err := savePartState(location, state); err != nil {
+				return state, offset, fmt.Errorf("can't save partial download state: %w", err)
+			}
+		}
+	default:
+		return state, offset, &HTTPError{Status: res.StatusCode, URL: streamUrl}
 	}
 
-	out, err := os.OpenFile(location, os.O_CREATE|os.O_WRONLY, 0644)
+	out, err := os.OpenFile(partLocation(location), flags, 0644)
 	if err != nil {
-		return fmt.Errorf("can't create file %s: %w", location, err)
+		return state, offset, fmt.Errorf("can't create file %s: %w", partLocation(location), err)
 	}
 	defer out.Close()
 
 	if tk != nil {
-		err = track.TrackProgress(tk, res, out)
-		if err != nil {
-			return fmt.Errorf("download with progress failed: %w", err)
-		}
-	} else {
-		_, err = io.Copy(out, res.Body)
-		if err != nil {
-			return fmt.Errorf("download failed: %w", err)
+		if state.Size > 0 {
+			tk.UpdateTotal(state.Size)
 		}
+		tk.SetValue(offset)
 	}
 
-	err = _addMetadata(location, Metadatas{
-		Title:       track.Title,
-		Artist:      track.Artist,
-		Album:       track.Album,
-		Date:        track.ReleaseDate,
-		Cover:       track.Cover,
-		TrackNumber: track.TrackNumber,
-	})
+	body := &ProgressReader{
+		Reader:     res.Body,
+		Tracker:    tk,
+		OnProgress: c.progressEmitter(track, location, state.Size),
+		Progress:   offset,
+	}
+
+	written, err := io.CopyBuffer(out, body, make([]byte, 32*1024))
+	offset += written
 
 	if err != nil {
-		return fmt.Errorf("cannot add metadata: %w", err)
+		return state, offset, fmt.Errorf("download failed: %w", err)
 	}
 
-	return nil
+	if state.Size <= 0 {
+		state.Size = offset
+	}
+
+	return state, offset, nil
 }
 
 func (track *Track) Download(format int) error {
-	var rootFolder = fmt.Sprintf("%s/%s", config.GetDownloadLocation(), SanitizeFilename(track.Artist))
+	return track.DownloadContext(context.Background(), format)
+}
 
-	if !DirExists(rootFolder) {
-		os.MkdirAll(rootFolder, 0755)
-	}
+func (track *Track) DownloadContext(ctx context.Context, format int) error {
+	return clientOrDefault(track.client).DownloadTrackContext(ctx, track, format)
+}
 
-	fileFormat := "flac"
+func (c *Client) DownloadTrack(track *Track, format int) error {
+	return c.DownloadTrackContext(context.Background(), track, format)
+}
 
-	if format == 5 {
-		fileFormat = "mp3"
+func (c *Client) DownloadTrackContext(ctx context.Context, track *Track, format int) error {
+	template, err := ParseTemplate(c.trackTemplate())
+	if err != nil {
+		return err
 	}
 
-	location := fmt.Sprintf("%s/%s.%s", rootFolder, SanitizeFilename(track.Title), fileFormat)
+	location := existingLocation(filepath.Join(c.downloadLocation(), template.Render(templateFields(track, nil))) + "." + qualityExtension(format))
+
+	if !DirExists(filepath.Dir(location)) {
+		os.MkdirAll(filepath.Dir(location), 0755)
+	}
 
-	if FileExists(location) {
+	if FileExists(location) && c.Options.Existing == ExistingFail && !c.Options.Verify {
 		return fmt.Errorf("track already found at path %s", location)
 	}
 
+	if c.skipExisting(ctx, track, location, format) {
+		PrintColor(COLOR_YELLOW, "Track %s already downloaded, skipping", track.Title)
+		c.emit(TrackResult{Track: *track, Location: location, Skipped: true})
+		return nil
+	}
+
 	PrintColor(COLOR_GREEN, "Starting download for track %s\n", track.Title)
 
 	pw := InitProgress()
-	sizes := GetTrackersTrackSizes([]Track{*track}, format)
+	sizes := c.GetTrackersTrackSizes(ctx, []Track{*track}, format)
 
 	if len(sizes) == 0 {
 		return fmt.Errorf("unable to get size of track: %s", track.Title)
 	}
 
 	pw.AppendTracker(sizes[0])
-	go pw.Render()
+	c.render(pw)
+	defer StopProgress(pw)
 
-	err := track.downloadTrack(location, format, sizes[0])
+	location, quality, err := c.downloadTrack(ctx, track, location, format, sizes[0])
+	c.emit(TrackResult{Track: *track, Location: location, Quality: quality, Err: err})
 
 	if err != nil {
 		return fmt.Errorf("download failed: %w", err)
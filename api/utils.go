@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/jedib0t/go-pretty/table"
@@ -10,6 +11,21 @@ import (
 
 type Color int
 
+// writePrivateFile writes data to location readable by the user only,
+// creating its directory. WriteFile keeps the mode of an existing file,
+// hence the Chmod.
+func writePrivateFile(location string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(location), 0700); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(location, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Chmod(location, 0600)
+}
+
 var colorMapping = map[Color]string{
 	COLOR_RESET:  "\033[0m",
 	COLOR_RED:    "\033[31m",
@@ -34,19 +50,16 @@ const (
 	COLOR_WHITE
 )
 
-var FormatMap = map[string]int{
-	"mp3":  5,
-	"flac": 27,
-}
-
 func PrintError(msg string) {
 	PrintColor(COLOR_RED, "%s", msg)
 	os.Exit(1)
 }
 
+// CheckErr prints err and exits with the code of its class, see ExitCode.
 func CheckErr(err error) {
 	if err != nil {
-		PrintError(err.Error())
+		PrintColor(COLOR_RED, "%s", err)
+		os.Exit(ExitCode(err))
 	}
 }
 
@@ -107,9 +120,9 @@ func PrintResultsTable(results *SearchResults, resultType string) {
 			tw.AppendRow(table.Row{idx, album.Id, album.Title, album.Artist, album.ReleaseDate})
 		}
 	case "artist":
-		tw.AppendHeader(table.Row{colIndex, "Artist ID", "Name"})
+		tw.AppendHeader(table.Row{colIndex, "Artist ID", "Name", "Albums"})
 		for idx, artist := range results.Artists.Items {
-			tw.AppendRow(table.Row{idx, artist.Id, artist.Name})
+			tw.AppendRow(table.Row{idx, artist.Id, artist.Name, artist.AlbumsCount})
 		}
 	}
 
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"godab/api"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var batchInput string
+var batchType string
+var batchJobs int
+var batchReport string
+
+const (
+	batchDone    = "done"
+	batchSkipped = "skipped"
+	batchFailed  = "failed"
+	batchPending = "pending"
+)
+
+type batchTrack struct {
+	Id       api.ID `json:"id"`
+	Title    string `json:"title"`
+	Location string `json:"location,omitempty"`
+	Quality  int    `json:"quality,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// batchEntry is one line of the input, as written in the report.
+type batchEntry struct {
+	Line    int          `json:"line"`
+	Input   string       `json:"input"`
+	Kind    string       `json:"type,omitempty"`
+	Id      string       `json:"id,omitempty"`
+	Status  string       `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Done    []batchTrack `json:"done,omitempty"`
+	Skipped []batchTrack `json:"skipped,omitempty"`
+	Failed  []batchTrack `json:"failed,omitempty"`
+
+	target target
+	errs   []error
+	mu     sync.Mutex
+}
+
+type batchReportFile struct {
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Done       int           `json:"done"`
+	Skipped    int           `json:"skipped"`
+	Failed     int           `json:"failed"`
+	Duplicates int           `json:"duplicates"`
+	Entries    []*batchEntry `json:"entries"`
+}
+
+func (e *batchEntry) record(result api.TrackResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	track := batchTrack{Id: result.Track.Id, Title: result.Track.Title, Location: result.Location, Quality: result.Quality}
+
+	switch {
+	case result.Err != nil:
+		track.Error = result.Err.Error()
+		e.Failed = append(e.Failed, track)
+		e.errs = append(e.errs, result.Err)
+	case result.Skipped:
+		e.Skipped = append(e.Skipped, track)
+	default:
+		e.Done = append(e.Done, track)
+	}
+}
+
+// finish sets the status out of the download error and the track results.
+func (e *batchEntry) finish(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case err != nil:
+		e.Status = batchFailed
+		e.Error = err.Error()
+		e.errs = append(e.errs, err)
+	case len(e.Failed) > 0:
+		e.Status = batchFailed
+		e.Error = fmt.Sprintf("%d tracks failed", len(e.Failed))
+	case len(e.Done) == 0 && len(e.Skipped) > 0:
+		e.Status = batchSkipped
+	default:
+		e.Status = batchDone
+	}
+}
+
+// readBatch parses one ID or URL per line. Empty lines and everything after
+// a "#" starting a line or following a space are ignored, duplicates are
+// only kept once.
+func readBatch(r io.Reader, defaultKind string) ([]*batchEntry, int, error) {
+	var entries []*batchEntry
+	seen := make(map[target]bool)
+	duplicates := 0
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+		if before, _, found := strings.Cut(text, " #"); found {
+			text = before
+		}
+
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		entry := &batchEntry{Line: line, Input: text}
+
+		t, err := resolveInput(text, defaultKind)
+		if err != nil {
+			entry.Status = batchFailed
+			entry.Error = err.Error()
+			entries = append(entries, entry)
+			continue
+		}
+
+		if seen[t] {
+			duplicates++
+			continue
+		}
+		seen[t] = true
+
+		entry.target = t
+		entry.Kind = t.Kind
+		entry.Id = t.Id
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("unable to read batch input: %w", err)
+	}
+
+	return entries, duplicates, nil
+}
+
+func openBatchInput(args []string) (io.ReadCloser, error) {
+	path := batchInput
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if path == "" || path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(path)
+}
+
+func writeBatchReport(report batchReportFile) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode report: %w", err)
+	}
+
+	if batchReport == "-" {
+		_, err = fmt.Println(string(data))
+		return err
+	}
+
+	return os.WriteFile(batchReport, append(data, '\n'), 0644)
+}
+
+var batchCmd = &cobra.Command{
+	Use:   "batch [file]",
+	Short: "Download every ID or URL listed in a file",
+	Long: `Download every ID or URL listed in a file, or read from stdin when no
+file is given. Lines accept the same inputs as get, "#" starts a comment.
+A JSON report of the downloaded, skipped and failed entries is written at
+the end.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(entityKinds, batchType) {
+			exitUsage("You can download only: track, album and artist")
+		}
+
+		if batchJobs <= 0 {
+			exitUsage("--jobs must be a positive number")
+		}
+
+		input, err := openBatchInput(args)
+		api.CheckErr(err)
+
+		entries, duplicates, err := readBatch(input, batchType)
+		input.Close()
+		api.CheckErr(err)
+
+		format := getFormat()
+		applySyncFlags()
+		if !overwrite {
+			api.DefaultClient.Options.Existing = api.ExistingSkip
+		}
+		applyTemplate(&api.DefaultClient.Options.TrackTemplate)
+		applyTemplate(&api.DefaultClient.Options.AlbumTemplate)
+		api.DefaultClient.Options.HideProgress = api.DefaultClient.Options.HideProgress || batchJobs > 1
+		ctx := cmd.Context()
+		summary := newDownloadSummary()
+
+		report := batchReportFile{StartedAt: time.Now(), Duplicates: duplicates, Entries: entries}
+		api.PrintColor(api.COLOR_BLUE, "%d entries to download, %d duplicates ignored", len(entries), duplicates)
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, batchJobs)
+
+		for _, entry := range entries {
+			if entry.Status != "" {
+				api.PrintColor(api.COLOR_RED, "line %d: %s", entry.Line, entry.Error)
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+
+			wg.Add(1)
+			go func(entry *batchEntry) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				options := api.DefaultClient.Options
+				options.OnTrackResult = func(result api.TrackResult) {
+					summary.record(result)
+					entry.record(result)
+				}
+				client := api.DefaultClient.WithOptions(options)
+
+				err := downloadTarget(ctx, client, entry.target, format)
+				entry.finish(err)
+
+				if entry.Status == batchFailed {
+					api.PrintColor(api.COLOR_RED, "%s %s: %s", entry.Kind, entry.Id, entry.Error)
+				} else {
+					api.PrintColor(api.COLOR_GREEN, "%s %s: %s", entry.Kind, entry.Id, entry.Status)
+				}
+			}(entry)
+		}
+
+		wg.Wait()
+
+		report.FinishedAt = time.Now()
+		for _, entry := range entries {
+			switch entry.Status {
+			case "":
+				entry.Status = batchPending
+			case batchDone:
+				report.Done++
+			case batchSkipped:
+				report.Skipped++
+			case batchFailed:
+				report.Failed++
+			}
+		}
+
+		api.CheckErr(writeBatchReport(report))
+		summary.exitIfInterrupted(ctx)
+
+		api.PrintColor(api.COLOR_BLUE, "%d done, %d skipped, %d failed", report.Done, report.Skipped, report.Failed)
+		if batchReport != "-" {
+			api.PrintColor(api.COLOR_BLUE, "Report written to %s", batchReport)
+		}
+
+		if report.Failed > 0 {
+			var errs []error
+			for _, entry := range report.Entries {
+				errs = append(errs, entry.errs...)
+			}
+			exitFailed(errs)
+		}
+	},
+}
+
+func init() {
+	batchCmd.Flags().StringVarP(&batchInput, "input", "i", "", "File to read, - or nothing for stdin")
+	batchCmd.Flags().StringVarP(&batchType, "type", "t", "album", "Type of bare IDs (track, album, artist)")
+	batchCmd.Flags().IntVarP(&batchJobs, "jobs", "j", 2, "Number of entries downloaded at once")
+	batchCmd.Flags().StringVarP(&batchReport, "report", "r", "batch-report.json", "Where to write the JSON report, - for stdout")
+	rootCmd.AddCommand(batchCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"godab/api"
+	"godab/config"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:         "config",
+	Short:       "Read and edit the config file",
+	Annotations: map[string]string{offlineAnnotation: "true"},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the config file",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(config.GetConfigPath())
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every setting of the active profile and where it comes from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		api.PrintColor(api.COLOR_BLUE, "Profile %s (available: %s)", config.GetProfile(), strings.Join(config.Profiles(), ", "))
+
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{"Key", "Value", "Source"})
+		for _, setting := range config.Settings {
+			value, source := config.Source(setting.Key)
+			tw.AppendRow(table.Row{setting.Key, value, source})
+		}
+
+		fmt.Println(tw.Render())
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		value, source := config.Source(args[0])
+		if source == "" {
+			exitUsage(fmt.Sprintf("unknown config key %s", args[0]))
+		}
+
+		fmt.Println(value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> [value]",
+	Short: "Store a setting in the active profile, without value it is removed",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[0]
+		value := ""
+		if len(args) == 2 {
+			value = args[1]
+		}
+
+		checkUsage(config.Validate(key, value))
+
+		switch {
+		case value == "":
+		case key == "format":
+			_, err := api.ParseQualities(value)
+			checkUsage(err)
+		case key == "album_template" || key == "track_template":
+			_, err := api.ParseTemplate(value)
+			checkUsage(err)
+		}
+
+		api.CheckErr(config.Set(key, value))
+		api.PrintColor(api.COLOR_GREEN, "%s saved in profile %s", key, config.GetProfile())
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
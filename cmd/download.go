@@ -1,22 +1,77 @@
 package cmd
 
 import (
+	"cmp"
 	"godab/api"
-	"strings"
+	"godab/config"
 
 	"github.com/spf13/cobra"
 )
 
 var downloadFormat string
+var downloadQuality string
+var skipExisting bool
+var overwrite bool
+var verify bool
+var pathTemplate string
+var writePlaylists bool
+var discography api.DiscographyFilter
+
+// concurrencyFlags are the download flags overriding a setting, by setting.
+var concurrencyFlags = map[string]string{
+	"concurrency":       "concurrency",
+	"album-concurrency": "album_concurrency",
+	"host-concurrency":  "host_concurrency",
+}
 
+// getFormat returns the quality to download and sets the fallback ones.
+// --format gives a single tier, --quality and the format setting a list.
 func getFormat() int {
-	format := api.FormatMap[strings.ToLower(downloadFormat)]
+	var qualities []int
+	var err error
+
+	switch {
+	case downloadQuality != "":
+		qualities, err = api.ParseQualities(downloadQuality)
+	case downloadFormat != "":
+		var format int
+		format, err = api.ParseQuality(downloadFormat)
+		qualities = []int{format}
+	default:
+		qualities, err = api.ParseQualities(cmp.Or(config.GetFormat(), "flac"))
+	}
+	checkUsage(err)
+
+	api.DefaultClient.Options.Fallback = qualities[1:]
+	return qualities[0]
+}
 
-	if format == 0 {
-		format = 27
+func applySyncFlags() {
+	switch {
+	case overwrite:
+		api.DefaultClient.Options.Existing = api.ExistingOverwrite
+	case skipExisting:
+		api.DefaultClient.Options.Existing = api.ExistingSkip
 	}
 
-	return format
+	api.DefaultClient.Options.Verify = verify
+	api.DefaultClient.Options.WritePlaylists = writePlaylists
+
+	checkUsage(api.ValidateDiscographyFilter(discography))
+	api.DefaultClient.Options.Discography = discography
+}
+
+// applyTemplate sets the layout template given with --template, it is the
+// track one for single tracks and the album one otherwise.
+func applyTemplate(target *string) {
+	if pathTemplate == "" {
+		return
+	}
+
+	_, err := api.ParseTemplate(pathTemplate)
+	checkUsage(err)
+
+	*target = pathTemplate
 }
 
 var trackCmd = &cobra.Command{
@@ -27,10 +82,15 @@ var trackCmd = &cobra.Command{
 		id := args[0]
 
 		format := getFormat()
+		applySyncFlags()
+		applyTemplate(&api.DefaultClient.Options.TrackTemplate)
+		ctx := cmd.Context()
+		summary := newDownloadSummary()
 
-		track, err := api.NewTrack(id)
+		track, err := api.DefaultClient.NewTrackContext(ctx, id)
 		api.CheckErr(err)
-		err = track.Download(format)
+		err = track.DownloadContext(ctx, format)
+		summary.exitIfInterrupted(ctx)
 		api.CheckErr(err)
 	},
 }
@@ -43,11 +103,16 @@ var albumCmd = &cobra.Command{
 		id := args[0]
 
 		format := getFormat()
+		applySyncFlags()
+		applyTemplate(&api.DefaultClient.Options.AlbumTemplate)
+		ctx := cmd.Context()
+		summary := newDownloadSummary()
 
-		album, err := api.NewAlbum(id)
+		album, err := api.DefaultClient.NewAlbumContext(ctx, id)
 		api.CheckErr(err)
 
-		err = album.Download(format, true)
+		err = album.DownloadContext(ctx, format, true)
+		summary.exitIfInterrupted(ctx)
 		api.CheckErr(err)
 	},
 }
@@ -60,19 +125,44 @@ var artistCmd = &cobra.Command{
 		id := args[0]
 
 		format := getFormat()
+		applySyncFlags()
+		applyTemplate(&api.DefaultClient.Options.AlbumTemplate)
+		ctx := cmd.Context()
+		summary := newDownloadSummary()
 
-		artist, err := api.NewArtist(id)
+		artist, err := api.DefaultClient.NewArtistContext(ctx, id)
 		api.CheckErr(err)
 
-		err = artist.Download(format)
+		err = artist.DownloadContext(ctx, format)
+		summary.exitIfInterrupted(ctx)
 		api.CheckErr(err)
 	},
 }
 
 func init() {
-	trackCmd.Flags().StringVarP(&downloadFormat, "format", "f", "", "Download format")
-	albumCmd.Flags().StringVarP(&downloadFormat, "format", "f", "", "Download format")
-	artistCmd.Flags().StringVarP(&downloadFormat, "format", "f", "", "Download format")
+	for _, c := range []*cobra.Command{trackCmd, albumCmd, artistCmd, getCmd, batchCmd} {
+		c.Flags().StringVarP(&downloadFormat, "format", "f", "", "Download format: mp3, cd, hires-96, hires-192 (flac) or 5, 6, 7, 27")
+		c.Flags().StringVarP(&downloadQuality, "quality", "q", "", "Quality tiers tried in order per track, e.g. 27,7,6,5")
+		c.MarkFlagsMutuallyExclusive("format", "quality")
+		c.Flags().BoolVar(&skipExisting, "skip-existing", false, "Only download tracks missing from the download location")
+		c.Flags().BoolVar(&overwrite, "overwrite", false, "Download again tracks that already exist")
+		c.Flags().BoolVar(&verify, "verify", false, "Check size and tags of existing tracks and download again the incomplete ones")
+		c.Flags().StringVarP(&pathTemplate, "template", "T", "", "Layout of the downloaded files, e.g. \"{albumartist}/{year} - {album}/{track:02} {title}\"")
+		c.MarkFlagsMutuallyExclusive("skip-existing", "overwrite")
+		c.Flags().Int("concurrency", 0, "Tracks downloaded at once (default concurrency setting)")
+		c.Flags().Int("album-concurrency", 0, "Albums of an artist downloaded at once (default album_concurrency setting)")
+		c.Flags().Int("host-concurrency", 0, "Transfers at once from a single host, 0 for no limit (default host_concurrency setting)")
+	}
+	for _, c := range []*cobra.Command{albumCmd, artistCmd, getCmd, batchCmd} {
+		c.Flags().BoolVar(&writePlaylists, "m3u", false, "Write a M3U8 playlist per album, and one per artist")
+	}
+	for _, c := range []*cobra.Command{artistCmd, getCmd, batchCmd} {
+		c.Flags().StringSliceVar(&discography.IncludeTypes, "types", nil, "Only download these release types of artists (album, ep, single, compilation)")
+		c.Flags().StringSliceVar(&discography.ExcludeTypes, "exclude-types", nil, "Don't download these release types of artists")
+		c.Flags().IntVar(&discography.YearFrom, "year-from", 0, "Only download artist albums released this year or later")
+		c.Flags().IntVar(&discography.YearTo, "year-to", 0, "Only download artist albums released this year or earlier")
+		c.Flags().StringSliceVar(&discography.Dedupe, "dedupe", nil, "Download one edition of every artist album, preferring in order explicit, clean, quality, latest or original")
+	}
 	rootCmd.AddCommand(trackCmd)
 	rootCmd.AddCommand(albumCmd)
 	rootCmd.AddCommand(artistCmd)
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"godab/api"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+var getType string
+
+// downloadTarget downloads t with client through the same path as the track,
+// album and artist commands.
+func downloadTarget(ctx context.Context, client *api.Client, t target, format int) error {
+	switch t.Kind {
+	case "track":
+		track, err := client.NewTrackContext(ctx, t.Id)
+		if err != nil {
+			return err
+		}
+		return track.DownloadContext(ctx, format)
+	case "album":
+		album, err := client.NewAlbumContext(ctx, t.Id)
+		if err != nil {
+			return err
+		}
+		return album.DownloadContext(ctx, format, true)
+	case "artist":
+		artist, err := client.NewArtistContext(ctx, t.Id)
+		if err != nil {
+			return err
+		}
+		return artist.DownloadContext(ctx, format)
+	}
+
+	return fmt.Errorf("unknown type %s", t.Kind)
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <url|type:id|id>...",
+	Short: "Download tracks, albums and artists from URLs or IDs",
+	Long: `Download every input, which can be a web player URL, an ID prefixed
+with its type (track:123, album:123, artist:123) or a bare ID of --type.`,
+	Example: `  godab get "https://dabmusic.xyz/album/123" track:456 artist:789`,
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(entityKinds, getType) {
+			exitUsage("You can download only: track, album and artist")
+		}
+
+		// every input is resolved first so a typo doesn't stop a download halfway
+		var targets []target
+		for _, arg := range args {
+			t, err := resolveInput(arg, getType)
+			checkUsage(err)
+			targets = append(targets, t)
+		}
+
+		format := getFormat()
+		applySyncFlags()
+		applyTemplate(&api.DefaultClient.Options.TrackTemplate)
+		applyTemplate(&api.DefaultClient.Options.AlbumTemplate)
+		ctx := cmd.Context()
+		summary := newDownloadSummary()
+
+		var errs []error
+		for _, t := range targets {
+			err := downloadTarget(ctx, api.DefaultClient, t, format)
+			summary.exitIfInterrupted(ctx)
+
+			if err != nil {
+				errs = append(errs, err)
+				api.PrintColor(api.COLOR_RED, "%s %s: %s", t.Kind, t.Id, err)
+			}
+		}
+
+		if len(errs) > 0 {
+			api.PrintColor(api.COLOR_RED, "%d of %d downloads failed", len(errs), len(targets))
+			exitFailed(errs)
+		}
+	},
+}
+
+func init() {
+	getCmd.Flags().StringVarP(&getType, "type", "t", "album", "Type of bare IDs (track, album, artist)")
+	rootCmd.AddCommand(getCmd)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"godab/api"
+	"godab/config"
+	"godab/playlist"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+)
+
+var minScore float64
+var importDryRun bool
+var importOutput string
+
+var importPlaylistCmd = &cobra.Command{
+	Use:   "import-playlist <file>",
+	Short: "Download the tracks of a CSV, M3U, XSPF or JSPF playlist",
+	Long: `Search the catalog for every entry of a playlist exported from another
+service, download the best matches and write a M3U8 playlist of the
+downloaded files.
+
+CSV files need a header naming their columns, title and artist at least,
+album, duration (or duration_ms) and isrc when available.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		p, err := playlist.Read(args[0])
+		api.CheckErr(err)
+
+		if len(p.Entries) == 0 {
+			api.CheckErr(fmt.Errorf("the playlist %s has no entries", args[0]))
+		}
+
+		format := getFormat()
+		applySyncFlags()
+		if !overwrite {
+			api.DefaultClient.Options.Existing = api.ExistingSkip
+		}
+		applyTemplate(&api.DefaultClient.Options.TrackTemplate)
+		ctx := cmd.Context()
+		summary := newDownloadSummary()
+
+		api.PrintColor(api.COLOR_BLUE, "Matching %d entries of %s", len(p.Entries), p.Name)
+
+		var matched []*api.Match
+		var rejected []*api.Match
+
+		for _, entry := range p.Entries {
+			match, err := api.DefaultClient.MatchTrackContext(ctx, entry)
+			summary.exitIfInterrupted(ctx)
+
+			if err != nil {
+				api.PrintColor(api.COLOR_RED, "#%d %s - %s: %s", entry.Row, entry.Artist, entry.Title, err)
+				rejected = append(rejected, &api.Match{Entry: entry})
+				continue
+			}
+
+			if match.Track == nil || match.Score < minScore {
+				rejected = append(rejected, match)
+				continue
+			}
+
+			matched = append(matched, match)
+		}
+
+		api.PrintColor(api.COLOR_GREEN, "%d entries matched, %d unmatched or below %.2f", len(matched), len(rejected), minScore)
+		printRejected(rejected)
+
+		if importDryRun {
+			return
+		}
+
+		locations := make(map[api.ID]string)
+		api.DefaultClient.Options.OnTrackResult = func(result api.TrackResult) {
+			summary.record(result)
+			if result.Err == nil {
+				locations[result.Track.Id] = result.Location
+			}
+		}
+
+		var items []playlist.Item
+		var errs []error
+		failed := 0
+
+		for _, match := range matched {
+			track, err := api.DefaultClient.NewTrackContext(ctx, strconv.Itoa(int(match.Track.Id)))
+			if err == nil {
+				err = track.DownloadContext(ctx, format)
+			}
+			summary.exitIfInterrupted(ctx)
+
+			location, ok := locations[match.Track.Id]
+			if err != nil || !ok {
+				failed++
+				errs = append(errs, err)
+				api.PrintColor(api.COLOR_RED, "#%d %s - %s: %v", match.Entry.Row, match.Track.Artist, match.Track.Title, err)
+				continue
+			}
+
+			items = append(items, playlist.Item{
+				Path:     location,
+				Title:    fmt.Sprintf("%s - %s", track.Artist, track.Title),
+				Duration: track.Duration,
+			})
+		}
+
+		output := importOutput
+		if output == "" {
+			output = filepath.Join(config.GetDownloadLocation(), api.SanitizeFilename(p.Name)+".m3u8")
+		}
+
+		api.CheckErr(playlist.WriteM3U8(output, p.Name, items))
+		api.PrintColor(api.COLOR_GREEN, "Playlist of %d tracks written to %s", len(items), output)
+
+		if failed > 0 || len(rejected) > 0 {
+			exitFailed(errs)
+		}
+	},
+}
+
+func printRejected(rejected []*api.Match) {
+	if len(rejected) == 0 {
+		return
+	}
+
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Row", "Artist", "Title", "Best candidate", "Score"})
+	for _, match := range rejected {
+		candidate := "-"
+		score := "-"
+		if match.Track != nil {
+			candidate = fmt.Sprintf("%s - %s (ID: %d)", match.Track.Artist, match.Track.Title, match.Track.Id)
+			score = fmt.Sprintf("%.2f", match.Score)
+		}
+
+		tw.AppendRow(table.Row{match.Entry.Row, match.Entry.Artist, match.Entry.Title, candidate, score})
+	}
+
+	fmt.Fprintln(os.Stderr, tw.Render())
+}
+
+func init() {
+	importPlaylistCmd.Flags().Float64Var(&minScore, "min-score", 0.8, "Lowest match score (0 to 1) downloaded")
+	importPlaylistCmd.Flags().BoolVarP(&importDryRun, "dry-run", "n", false, "Only match the entries and report the unmatched ones")
+	importPlaylistCmd.Flags().StringVar(&importOutput, "playlist", "", "Where to write the M3U8 playlist (default <download location>/<playlist name>.m3u8)")
+	rootCmd.AddCommand(importPlaylistCmd)
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var remember bool
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login using credentials",
@@ -14,17 +16,23 @@ var loginCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		email := args[0]
 		password := args[1]
-		err := api.Login(email, password)
+		err := api.DefaultClient.LoginContext(cmd.Context(), email, password)
 
 		if err != nil {
 			api.PrintColor(api.COLOR_RED, "%s", err)
-			os.Exit(1)
+			os.Exit(api.ExitCode(err))
 		}
 
 		api.PrintColor(api.COLOR_GREEN, "Login successfull")
+
+		if remember {
+			api.CheckErr(api.DefaultClient.RememberCredentials(api.Credentials{Email: email, Password: password}))
+			api.PrintColor(api.COLOR_GREEN, "Credentials stored, expired sessions will be renewed automatically")
+		}
 	},
 }
 
 func init() {
+	loginCmd.Flags().BoolVarP(&remember, "remember", "r", false, "Store the credentials encrypted to log in again when the session expires")
 	rootCmd.AddCommand(loginCmd)
 }
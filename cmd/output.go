@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"godab/api"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+	outputCSV   = "csv"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputJSONL, outputCSV}
+
+var outputFormat string
+
+func validateOutput() error {
+	if !slices.Contains(outputFormats, outputFormat) {
+		return fmt.Errorf("unknown output %s, use one of table, json, jsonl or csv", outputFormat)
+	}
+	return nil
+}
+
+// applyOutput swaps progress bars for one JSON object or CSV row per track
+// event on stdout, human readable messages keep going to stderr.
+func applyOutput() {
+	if outputFormat == outputTable {
+		return
+	}
+
+	var mu sync.Mutex
+	writeEvent := eventWriter()
+
+	api.DefaultClient.Options.HideProgress = true
+	api.DefaultClient.Options.OnTrackEvent = func(event api.TrackEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		writeEvent(event)
+	}
+}
+
+func eventWriter() func(api.TrackEvent) {
+	if outputFormat != outputCSV {
+		encoder := json.NewEncoder(os.Stdout)
+		return func(event api.TrackEvent) {
+			encoder.Encode(event)
+		}
+	}
+
+	writer := csv.NewWriter(os.Stdout)
+	header := false
+
+	return func(event api.TrackEvent) {
+		if !header {
+			writer.Write([]string{"event", "time", "track_id", "title", "artist", "album", "location", "bytes", "total", "quality", "error"})
+			header = true
+		}
+
+		writer.Write([]string{
+			string(event.Type),
+			event.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+			strconv.Itoa(int(event.TrackId)),
+			event.Title,
+			event.Artist,
+			event.Album,
+			event.Location,
+			strconv.FormatInt(event.Bytes, 10),
+			strconv.FormatInt(event.Total, 10),
+			strconv.Itoa(event.Quality),
+			event.Error,
+		})
+		writer.Flush()
+	}
+}
+
+func encodeLines[T any](items []T) error {
+	encoder := json.NewEncoder(os.Stdout)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printSearchResults writes results in the output format, json gives the
+// whole SearchResults while jsonl and csv give one line per item.
+func printSearchResults(results *api.SearchResults, resultType string) error {
+	switch outputFormat {
+	case outputJSON:
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode results: %w", err)
+		}
+		fmt.Println(string(data))
+	case outputJSONL:
+		switch resultType {
+		case "track":
+			return encodeLines(results.Tracks.Items)
+		case "album":
+			return encodeLines(results.Albums.Items)
+		case "artist":
+			return encodeLines(results.Artists.Items)
+		}
+	case outputCSV:
+		writer := csv.NewWriter(os.Stdout)
+		switch resultType {
+		case "track":
+			writer.Write([]string{"id", "title", "artist", "album", "album_id", "release_date", "duration", "isrc"})
+			for _, track := range results.Tracks.Items {
+				writer.Write([]string{strconv.Itoa(int(track.Id)), track.Title, track.Artist, track.Album, track.AlbumId, track.ReleaseDate, strconv.Itoa(track.Duration), track.ISRC})
+			}
+		case "album":
+			writer.Write([]string{"id", "title", "artist", "release_date", "track_count", "label", "upc"})
+			for _, album := range results.Albums.Items {
+				writer.Write([]string{album.Id, album.Title, album.Artist, album.ReleaseDate, strconv.Itoa(album.TrackCount), album.Label, album.UPC})
+			}
+		case "artist":
+			writer.Write([]string{"id", "name", "albums_count", "picture"})
+			for _, artist := range results.Artists.Items {
+				writer.Write([]string{strconv.Itoa(int(artist.Id)), artist.Name, strconv.Itoa(artist.AlbumsCount), artist.Picture})
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		api.PrintResultsTable(results, resultType)
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"godab/api"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+type pickerAction int
+
+const (
+	pickerQuit pickerAction = iota
+	pickerDownload
+)
+
+type pickerItem struct {
+	target target
+	label  string
+}
+
+type pickerScreen struct {
+	title  string
+	items  []pickerItem
+	cursor int
+	offset int
+}
+
+// picker is a full screen list of search results to pick from. Enter opens
+// artists and albums, the selection is kept while moving between screens.
+type picker struct {
+	ctx      context.Context
+	screens  []*pickerScreen
+	selected []pickerItem
+	message  string
+	enqueue  func([]pickerItem) string
+	height   int
+}
+
+func searchPickerItems(results *api.SearchResults, resultType string) []pickerItem {
+	var items []pickerItem
+
+	switch resultType {
+	case "track":
+		for _, track := range results.Tracks.Items {
+			items = append(items, pickerItem{
+				target: target{Kind: "track", Id: fmt.Sprint(track.Id)},
+				label:  fmt.Sprintf("%s - %s (%s)", track.Artist, track.Title, track.Album),
+			})
+		}
+	case "album":
+		for _, album := range results.Albums.Items {
+			items = append(items, pickerItem{
+				target: target{Kind: "album", Id: album.Id},
+				label:  fmt.Sprintf("%s - %s [%s]", album.Artist, album.Title, year(album.ReleaseDate)),
+			})
+		}
+	case "artist":
+		for _, artist := range results.Artists.Items {
+			items = append(items, pickerItem{
+				target: target{Kind: "artist", Id: fmt.Sprint(artist.Id)},
+				label:  fmt.Sprintf("%s (%d albums)", artist.Name, artist.AlbumsCount),
+			})
+		}
+	}
+
+	return items
+}
+
+func year(date string) string {
+	if len(date) >= 4 {
+		return date[:4]
+	}
+	return "----"
+}
+
+func (p *picker) current() *pickerScreen {
+	return p.screens[len(p.screens)-1]
+}
+
+func (p *picker) isSelected(t target) bool {
+	for _, item := range p.selected {
+		if item.target == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *picker) toggle() {
+	screen := p.current()
+	if len(screen.items) == 0 {
+		return
+	}
+
+	item := screen.items[screen.cursor]
+	for i, selected := range p.selected {
+		if selected.target == item.target {
+			p.selected = append(p.selected[:i], p.selected[i+1:]...)
+			return
+		}
+	}
+	p.selected = append(p.selected, item)
+}
+
+// selection is the selected items, or the one under the cursor when nothing
+// is selected.
+func (p *picker) selection() []pickerItem {
+	if len(p.selected) > 0 {
+		return p.selected
+	}
+
+	screen := p.current()
+	if len(screen.items) == 0 {
+		return nil
+	}
+	return []pickerItem{screen.items[screen.cursor]}
+}
+
+func (p *picker) move(delta int) {
+	screen := p.current()
+	screen.cursor = max(0, min(len(screen.items)-1, screen.cursor+delta))
+}
+
+// open drills into the artist discography or the album tracklist under the
+// cursor.
+func (p *picker) open() {
+	screen := p.current()
+	if len(screen.items) == 0 {
+		return
+	}
+
+	item := screen.items[screen.cursor]
+	p.message = "Loading..."
+	p.draw()
+
+	next := &pickerScreen{}
+
+	switch item.target.Kind {
+	case "artist":
+		artist, err := api.DefaultClient.NewArtistContext(p.ctx, item.target.Id)
+		if err != nil {
+			p.message = err.Error()
+			return
+		}
+
+		next.title = fmt.Sprintf("%s, %d albums", artist.Name, len(artist.Albums))
+		for _, album := range artist.Albums {
+			next.items = append(next.items, pickerItem{
+				target: target{Kind: "album", Id: album.Id},
+				label:  fmt.Sprintf("%s  %s (%d tracks)", year(album.ReleaseDate), album.Title, album.TrackCount),
+			})
+		}
+	case "album":
+		album, err := api.DefaultClient.NewAlbumContext(p.ctx, item.target.Id)
+		if err != nil {
+			p.message = err.Error()
+			return
+		}
+
+		next.title = fmt.Sprintf("%s - %s [%s]", album.Artist, album.Title, year(album.ReleaseDate))
+		for _, track := range album.Tracks {
+			next.items = append(next.items, pickerItem{
+				target: target{Kind: "track", Id: fmt.Sprint(track.Id)},
+				label:  fmt.Sprintf("%d-%02d  %s  %d:%02d", track.DiscNumber, track.TrackNumber, track.Title, track.Duration/60, track.Duration%60),
+			})
+		}
+	default:
+		p.message = "Tracks have nothing to open"
+		return
+	}
+
+	p.message = ""
+	p.screens = append(p.screens, next)
+}
+
+func (p *picker) draw() {
+	screen := p.current()
+	visible := max(1, p.height-4)
+
+	if screen.cursor < screen.offset {
+		screen.offset = screen.cursor
+	}
+	if screen.cursor >= screen.offset+visible {
+		screen.offset = screen.cursor - visible + 1
+	}
+
+	var out strings.Builder
+	out.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&out, "\x1b[1m%s\x1b[0m\r\n", screen.title)
+
+	for i := screen.offset; i < min(len(screen.items), screen.offset+visible); i++ {
+		item := screen.items[i]
+
+		mark := "[ ]"
+		if p.isSelected(item.target) {
+			mark = "[x]"
+		}
+
+		line := fmt.Sprintf("%s %-6s %s", mark, item.target.Kind, item.label)
+		if i == screen.cursor {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		out.WriteString(line + "\r\n")
+	}
+
+	if len(screen.items) == 0 {
+		out.WriteString("Nothing found\r\n")
+	}
+
+	fmt.Fprintf(&out, "\r\n%d selected  %s\r\n", len(p.selected), p.message)
+	out.WriteString("\x1b[2m↑↓ move  space select  enter open  ← back  d download  a queue  q quit\x1b[0m")
+
+	fmt.Fprint(os.Stderr, out.String())
+}
+
+// readKey returns the pressed key, escape sequences of arrows and page keys
+// are given names.
+func readKey() (string, error) {
+	buf := make([]byte, 8)
+	n, err := os.Stdin.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	switch key := string(buf[:n]); key {
+	case "\x1b[A", "\x1bOA":
+		return "up", nil
+	case "\x1b[B", "\x1bOB":
+		return "down", nil
+	case "\x1b[C", "\x1bOC":
+		return "right", nil
+	case "\x1b[D", "\x1bOD":
+		return "left", nil
+	case "\x1b[5~":
+		return "pgup", nil
+	case "\x1b[6~":
+		return "pgdown", nil
+	case "\x1b", "\x03":
+		return "quit", nil
+	case "\r", "\n":
+		return "enter", nil
+	case "\x7f", "\b":
+		return "left", nil
+	default:
+		return key, nil
+	}
+}
+
+// run shows the picker until the selection is downloaded or the user quits.
+func (p *picker) run() (pickerAction, []pickerItem, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return pickerQuit, nil, fmt.Errorf("interactive mode needs a terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return pickerQuit, nil, fmt.Errorf("unable to set up terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	// alternate screen without cursor, both restored on the way out
+	fmt.Fprint(os.Stderr, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stderr, "\x1b[?25h\x1b[?1049l")
+
+	for {
+		p.height = 24
+		if _, height, err := term.GetSize(int(os.Stderr.Fd())); err == nil {
+			p.height = height
+		}
+
+		p.draw()
+
+		key, err := readKey()
+		if err != nil {
+			return pickerQuit, nil, err
+		}
+
+		switch key {
+		case "up", "k":
+			p.move(-1)
+		case "down", "j":
+			p.move(1)
+		case "pgup":
+			p.move(-(p.height - 4))
+		case "pgdown":
+			p.move(p.height - 4)
+		case " ":
+			p.toggle()
+			p.move(1)
+		case "enter", "right", "l":
+			p.open()
+		case "left", "h":
+			if len(p.screens) > 1 {
+				p.screens = p.screens[:len(p.screens)-1]
+			}
+			p.message = ""
+		case "a":
+			if selection := p.selection(); len(selection) > 0 {
+				p.message = p.enqueue(selection)
+				p.selected = nil
+			}
+		case "d":
+			if selection := p.selection(); len(selection) > 0 {
+				return pickerDownload, selection, nil
+			}
+		case "q", "quit":
+			return pickerQuit, nil, nil
+		}
+	}
+}
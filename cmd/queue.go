@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"godab/api"
+	"godab/config"
+	"godab/queue"
+	"path/filepath"
+	"slices"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+)
+
+var queueType string
+var clearFinished bool
+
+func openQueue() *queue.Queue {
+	q, err := queue.Open(filepath.Join(config.GetConfigDir(), "queue.json"))
+	api.CheckErr(err)
+	return q
+}
+
+func fetchTitle(ctx context.Context, t target) (string, error) {
+	switch t.Kind {
+	case "track":
+		track, err := api.DefaultClient.NewTrackContext(ctx, t.Id)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s - %s", track.Artist, track.Title), nil
+	case "album":
+		album, err := api.DefaultClient.NewAlbumContext(ctx, t.Id)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s - %s", album.Artist, album.Title), nil
+	case "artist":
+		artist, err := api.DefaultClient.NewArtistContext(ctx, t.Id)
+		if err != nil {
+			return "", err
+		}
+		return artist.Name, nil
+	}
+
+	return "", fmt.Errorf("unknown type %s", t.Kind)
+}
+
+func runJob(ctx context.Context, q *queue.Queue, job *queue.Job) error {
+	api.DefaultClient.Options.Fallback = job.Fallback
+
+	switch job.Kind {
+	case "track":
+		track, err := api.DefaultClient.NewTrackContext(ctx, job.EntityId)
+		if err != nil {
+			return err
+		}
+
+		if err := q.SetTracks(job, []api.Track{*track}); err != nil {
+			return err
+		}
+
+		if job.IsTrackDone(track.Id) {
+			return nil
+		}
+
+		return track.DownloadContext(ctx, job.Format)
+	case "album":
+		album, err := api.DefaultClient.NewAlbumContext(ctx, job.EntityId)
+		if err != nil {
+			return err
+		}
+
+		if err := q.SetTracks(job, album.Tracks); err != nil {
+			return err
+		}
+
+		var remaining []api.Track
+		for _, track := range album.Tracks {
+			if !job.IsTrackDone(track.Id) {
+				remaining = append(remaining, track)
+			}
+		}
+
+		if len(remaining) == 0 {
+			return nil
+		}
+
+		album.Tracks = remaining
+		return album.DownloadContext(ctx, job.Format, true)
+	case "artist":
+		artist, err := api.DefaultClient.NewArtistContext(ctx, job.EntityId)
+		if err != nil {
+			return err
+		}
+
+		return artist.DownloadContext(ctx, job.Format)
+	}
+
+	return fmt.Errorf("unknown job type %s", job.Kind)
+}
+
+func runQueue(ctx context.Context, q *queue.Queue) {
+	jobs := q.Runnable()
+
+	if len(jobs) == 0 {
+		api.PrintColor(api.COLOR_YELLOW, "Nothing to run in the queue")
+		return
+	}
+
+	// Tracks finished by an earlier run are on disk already.
+	api.DefaultClient.Options.Existing = api.ExistingSkip
+	summary := newDownloadSummary()
+	failed := 0
+	var errs []error
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		api.PrintColor(api.COLOR_BLUE, "Job %d: %s %s", job.Id, job.Kind, job.Title)
+		api.CheckErr(q.SetStatus(job, queue.StatusRunning, nil))
+
+		api.DefaultClient.Options.OnTrackResult = func(result api.TrackResult) {
+			summary.record(result)
+
+			// Interrupted tracks stay pending for the next run.
+			if result.Err != nil && ctx.Err() != nil {
+				return
+			}
+
+			if err := q.RecordTrack(job, result); err != nil {
+				api.PrintColor(api.COLOR_RED, "%s", err)
+			}
+		}
+
+		err := runJob(ctx, q, job)
+
+		switch {
+		case ctx.Err() != nil:
+			api.CheckErr(q.SetStatus(job, queue.StatusPending, nil))
+		case err != nil:
+			failed++
+			errs = append(errs, err)
+			api.PrintColor(api.COLOR_RED, "Job %d failed: %s", job.Id, err)
+			api.CheckErr(q.SetStatus(job, queue.StatusFailed, err))
+		default:
+			api.CheckErr(q.SetStatus(job, queue.StatusDone, nil))
+		}
+	}
+
+	summary.exitIfInterrupted(ctx)
+
+	if failed > 0 {
+		api.PrintColor(api.COLOR_RED, "%d jobs failed, run 'queue retry-failed' to try them again", failed)
+		exitFailed(errs)
+	}
+
+	api.PrintColor(api.COLOR_GREEN, "Queue completed")
+}
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Manage the persistent download queue",
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add <url|id>...",
+	Short: "Add tracks, albums or artists to the queue",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(entityKinds, queueType) {
+			exitUsage("You can queue only: track, album and artist")
+		}
+
+		format := getFormat()
+		q := openQueue()
+
+		for _, arg := range args {
+			t, err := resolveInput(arg, queueType)
+			checkUsage(err)
+
+			title, err := fetchTitle(cmd.Context(), t)
+			api.CheckErr(err)
+
+			job, err := q.Add(t.Kind, t.Id, title, format, api.DefaultClient.Options.Fallback)
+			api.CheckErr(err)
+
+			api.PrintColor(api.COLOR_GREEN, "Queued %s %s as job %d", job.Kind, job.Title, job.Id)
+		}
+	},
+}
+
+var queueListCmd = &cobra.Command{
+	Use:         "list",
+	Annotations: map[string]string{offlineAnnotation: "true"},
+	Short:       "List the queued jobs",
+	Args:        cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		q := openQueue()
+
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{"Job", "Type", "ID", "Title", "Status", "Tracks", "Error"})
+		for _, job := range q.Jobs {
+			tracks := fmt.Sprintf("%d/%d", job.CountTracks(queue.StatusDone), len(job.Tracks))
+			tw.AppendRow(table.Row{job.Id, job.Kind, job.EntityId, job.Title, job.Status, tracks, job.Error})
+		}
+
+		fmt.Println(tw.Render())
+	},
+}
+
+var queueRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Download every pending job of the queue",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runQueue(cmd.Context(), openQueue())
+	},
+}
+
+var queueRetryFailedCmd = &cobra.Command{
+	Use:   "retry-failed",
+	Short: "Put failed jobs back in the queue and run it",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		q := openQueue()
+
+		count, err := q.RetryFailed()
+		api.CheckErr(err)
+
+		api.PrintColor(api.COLOR_YELLOW, "Retrying %d failed jobs", count)
+		runQueue(cmd.Context(), q)
+	},
+}
+
+var queueClearCmd = &cobra.Command{
+	Use:         "clear",
+	Annotations: map[string]string{offlineAnnotation: "true"},
+	Short:       "Remove jobs from the queue",
+	Args:        cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		api.CheckErr(openQueue().Clear(clearFinished))
+		api.PrintColor(api.COLOR_GREEN, "Queue cleared")
+	},
+}
+
+func init() {
+	queueAddCmd.Flags().StringVarP(&queueType, "type", "t", "album", "Type of bare IDs (track, album, artist)")
+	queueAddCmd.Flags().StringVarP(&downloadFormat, "format", "f", "", "Download format")
+	queueAddCmd.Flags().StringVarP(&downloadQuality, "quality", "q", "", "Quality tiers tried in order, e.g. 27,7,6,5")
+	queueAddCmd.MarkFlagsMutuallyExclusive("format", "quality")
+	queueClearCmd.Flags().BoolVar(&clearFinished, "finished", false, "Only remove the finished jobs")
+
+	queueCmd.AddCommand(queueAddCmd)
+	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queueRunCmd)
+	queueCmd.AddCommand(queueRetryFailedCmd)
+	queueCmd.AddCommand(queueClearCmd)
+	rootCmd.AddCommand(queueCmd)
+}
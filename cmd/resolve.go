@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+var entityKinds = []string{"track", "album", "artist"}
+
+type target struct {
+	Kind string
+	Id   string
+}
+
+// resolveInput turns a web player URL, a prefixed ID like "album:123" or a
+// bare ID into a target. Bare IDs are given defaultKind.
+func resolveInput(input string, defaultKind string) (target, error) {
+	input = strings.TrimSpace(input)
+
+	if !strings.Contains(input, "://") {
+		kind, id, found := strings.Cut(input, ":")
+		if found {
+			kind = strings.ToLower(strings.TrimSpace(kind))
+			if !slices.Contains(entityKinds, kind) {
+				return target{}, fmt.Errorf("unknown type %s in %s", kind, input)
+			}
+		} else {
+			kind, id = defaultKind, input
+		}
+
+		if id = strings.TrimSpace(id); id == "" {
+			return target{}, fmt.Errorf("empty ID in %q", input)
+		}
+		return target{Kind: kind, Id: id}, nil
+	}
+
+	u, err := url.Parse(input)
+	if err != nil {
+		return target{}, fmt.Errorf("invalid URL %s", input)
+	}
+
+	for _, kind := range entityKinds {
+		if id := u.Query().Get(kind + "Id"); id != "" {
+			return target{Kind: kind, Id: id}, nil
+		}
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i < len(segments)-1; i++ {
+		kind := strings.ToLower(segments[i])
+		if slices.Contains(entityKinds, kind) && segments[i+1] != "" {
+			return target{Kind: kind, Id: segments[i+1]}, nil
+		}
+	}
+
+	return target{}, fmt.Errorf("can't find a track, album or artist in %s", input)
+}
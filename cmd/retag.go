@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"godab/api"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var retagDryRun bool
+
+var audioExtensions = []string{".flac", ".mp3", ".m4a"}
+
+var retagCmd = &cobra.Command{
+	Use:   "retag <path>",
+	Short: "Rewrite tags and cover art of downloaded files with fresh metadata",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		retagger := api.DefaultClient.NewRetagger(retagDryRun)
+
+		var updated, unchanged, skipped, failed int
+		var errs []error
+
+		err := filepath.WalkDir(args[0], func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if entry.IsDir() || !slices.Contains(audioExtensions, strings.ToLower(filepath.Ext(path))) {
+				return nil
+			}
+
+			changes, err := retagger.RetagContext(ctx, path)
+
+			switch {
+			case errors.Is(err, api.ErrNoTrackId):
+				skipped++
+				api.PrintColor(api.COLOR_GRAY, "%s: skipped, %s", path, err)
+			case err != nil:
+				failed++
+				errs = append(errs, err)
+				api.PrintColor(api.COLOR_RED, "%s: %s", path, err)
+			case len(changes) == 0:
+				unchanged++
+			default:
+				updated++
+				api.PrintColor(api.COLOR_BLUE, "%s", path)
+				for _, change := range changes {
+					if len(change.Old) > 0 {
+						api.PrintColor(api.COLOR_RED, "  - %s: %s", change.Key, strings.Join(change.Old, ", "))
+					}
+					api.PrintColor(api.COLOR_GREEN, "  + %s: %s", change.Key, strings.Join(change.New, ", "))
+				}
+			}
+
+			return nil
+		})
+		api.CheckErr(err)
+
+		verb := "Retagged"
+		if retagDryRun {
+			verb = "Would retag"
+		}
+
+		api.PrintColor(api.COLOR_GREEN, "%s %d files, %d unchanged, %d skipped, %d failed", verb, updated, unchanged, skipped, failed)
+
+		if failed > 0 {
+			api.PrintColor(api.COLOR_RED, "%d files could not be retagged", failed)
+			exitFailed(errs)
+		}
+	},
+}
+
+func init() {
+	retagCmd.Flags().BoolVarP(&retagDryRun, "dry-run", "n", false, "Only show the changes without writing them")
+	rootCmd.AddCommand(retagCmd)
+}
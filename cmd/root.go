@@ -1,12 +1,176 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"errors"
+	"godab/api"
+	"godab/config"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// offlineAnnotation marks commands that neither download nor need a session.
+const offlineAnnotation = "offline"
+
+var profile string
+var endpoint string
+var downloadLocation string
 
 var rootCmd = &cobra.Command{
 	Use:   "app",
 	Short: "A golang dabmusic.xyz downloader",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// config set is how a new profile gets created
+		if err := config.Load(profile); err != nil && !(cmd == configSetCmd && errors.Is(err, config.ErrProfileNotFound)) {
+			api.CheckErr(err)
+		}
+
+		if cmd.Flags().Changed("endpoint") {
+			config.SetFlag("endpoint", endpoint)
+		}
+		if cmd.Flags().Changed("download-location") {
+			config.SetFlag("download_location", downloadLocation)
+		}
+		for flag, key := range concurrencyFlags {
+			if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+				config.SetFlag(key, f.Value.String())
+			}
+		}
+
+		checkUsage(validateOutput())
+		api.ConfigureDefaultClient()
+		applyOutput()
+
+		if isOffline(cmd) {
+			return
+		}
+
+		if !api.DirExists(config.GetDownloadLocation()) {
+			exitUsage("You must provide a valid download_location folder")
+		}
+
+		loggedIn, err := api.LoadCookies()
+
+		if cmd == loginCmd {
+			return
+		}
+
+		if errors.Is(err, api.ErrSessionExpired) || (err == nil && !loggedIn) {
+			reloginErr := api.DefaultClient.Relogin(cmd.Context())
+
+			switch {
+			case reloginErr == nil:
+				api.PrintColor(api.COLOR_BLUE, "Logged in again with the stored credentials")
+				loggedIn, err = true, nil
+			case !errors.Is(reloginErr, api.ErrNoCredentials):
+				api.PrintColor(api.COLOR_YELLOW, "%s", reloginErr)
+			}
+		}
+
+		switch {
+		case errors.Is(err, api.ErrSessionExpired):
+			exitUnauthorized("Your session expired. Run 'login' command again.")
+		case err != nil:
+			exitUnauthorized("You're not logged-in. Run 'login' command first.")
+		case !loggedIn:
+			exitUnauthorized("You must be logged in to download from dabmusic")
+		}
+	},
+}
+
+func isOffline(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[offlineAnnotation] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// usageError marks the errors of invalid flags or arguments cobra returns.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// markUsageErrors makes the argument validators of cmd and its subcommands
+// return usageErrors.
+func markUsageErrors(cmd *cobra.Command) {
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return usageError{err}
+			}
+			return nil
+		}
+	}
+
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
 }
 
 func Execute() {
-	rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// a second signal kills the process instead of waiting for the
+	// downloads to wind down
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+	markUsageErrors(rootCmd)
+
+	err := rootCmd.ExecuteContext(ctx)
+	if err == nil {
+		return
+	}
+
+	// an unknown command fails the lookup, before any validator runs
+	var usage usageError
+	if _, _, findErr := rootCmd.Find(os.Args[1:]); errors.As(err, &usage) || findErr != nil {
+		os.Exit(api.ExitUsage)
+	}
+
+	os.Exit(api.ExitCode(err))
+}
+
+// exitUsage reports invalid flags or arguments.
+func exitUsage(msg string) {
+	api.PrintColor(api.COLOR_RED, "%s", msg)
+	os.Exit(api.ExitUsage)
+}
+
+// checkUsage exits with ExitUsage when a flag or argument is invalid.
+func checkUsage(err error) {
+	if err != nil {
+		exitUsage(err.Error())
+	}
+}
+
+func exitUnauthorized(msg string) {
+	api.PrintColor(api.COLOR_RED, "%s", msg)
+	os.Exit(api.ExitUnauthorized)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "Config profile to use")
+	rootCmd.PersistentFlags().StringVar(&endpoint, "endpoint", "", "Url of the dabmusic instance")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: table, json, jsonl or csv")
+	rootCmd.PersistentFlags().StringVar(&downloadLocation, "download-location", "", "Folder where files are downloaded")
 }
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"godab/api"
 	"slices"
 	"strings"
@@ -9,6 +11,9 @@ import (
 )
 
 var queryType string
+var interactive bool
+var searchOptions api.SearchOptions
+var searchPage int
 
 var searchCmd = &cobra.Command{
 	Use:   "search",
@@ -21,18 +26,103 @@ var searchCmd = &cobra.Command{
 		valid := slices.Contains(supportedTypes, strings.ToLower(queryType))
 
 		if !valid {
-			api.PrintError("You can search only by: track, artist and album")
+			exitUsage("You can search only by: track, artist and album")
 		}
 
-		results, err := api.Search(query, queryType)
+		if interactive && outputFormat != outputTable {
+			exitUsage("--interactive can't be used with --output")
+		}
+
+		if searchPage < 0 {
+			exitUsage("--page starts at 1")
+		}
+
+		if searchPage > 0 {
+			if cmd.Flags().Changed("offset") {
+				exitUsage("--page can't be used with --offset")
+			}
+			if searchOptions.Limit == 0 {
+				searchOptions.Limit = 10
+			}
+			searchOptions.Offset = (searchPage - 1) * searchOptions.Limit
+		}
+
+		if searchOptions.YearFrom > 0 && searchOptions.YearTo > 0 && searchOptions.YearFrom > searchOptions.YearTo {
+			exitUsage("--year-from can't be after --year-to")
+		}
+
+		results, err := api.DefaultClient.SearchWithContext(cmd.Context(), query, queryType, searchOptions)
 
 		api.CheckErr(err)
 
-		api.PrintResultsTable(results, queryType)
+		if !interactive {
+			api.CheckErr(printSearchResults(results, queryType))
+			return
+		}
+
+		pickAndDownload(cmd.Context(), fmt.Sprintf("Results for %q", query), searchPickerItems(results, strings.ToLower(queryType)))
 	},
 }
 
 func init() {
 	searchCmd.Flags().StringVarP(&queryType, "type", "t", "", "Query type (track, artist, album)")
+	searchCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Pick results to download or queue in an interactive list")
+	searchCmd.Flags().StringVarP(&downloadFormat, "format", "f", "", "Download format of the picked results")
+	searchCmd.Flags().StringVar(&downloadQuality, "quality", "", "Quality tiers of the picked results tried in order, e.g. 27,7,6,5")
+	searchCmd.MarkFlagsMutuallyExclusive("format", "quality")
+	searchCmd.Flags().IntVarP(&searchOptions.Limit, "limit", "l", 0, "Number of results, further pages are fetched until reached (default first page)")
+	searchCmd.Flags().IntVar(&searchOptions.Offset, "offset", 0, "Number of results to skip")
+	searchCmd.Flags().IntVar(&searchPage, "page", 0, "Page of --limit results to show, starting at 1 (limit defaults to 10)")
+	searchCmd.Flags().IntVar(&searchOptions.YearFrom, "year-from", 0, "Only results released this year or later")
+	searchCmd.Flags().IntVar(&searchOptions.YearTo, "year-to", 0, "Only results released this year or earlier")
+	searchCmd.Flags().StringVar(&searchOptions.Artist, "artist", "", "Only results by this exact artist name (case insensitive)")
+	searchCmd.Flags().IntVar(&searchOptions.MinTracks, "min-tracks", 0, "Only albums with at least this many tracks")
+	searchCmd.Flags().BoolVar(&searchOptions.HiResOnly, "hires", false, "Only hi-res results")
 	rootCmd.AddCommand(searchCmd)
 }
+
+// pickAndDownload opens the picker over items and downloads what is picked.
+func pickAndDownload(ctx context.Context, title string, items []pickerItem) {
+	format := getFormat()
+
+	p := &picker{
+		ctx:     ctx,
+		screens: []*pickerScreen{{title: title, items: items}},
+		enqueue: func(selection []pickerItem) string {
+			q := openQueue()
+
+			queued := 0
+			for _, item := range selection {
+				if _, err := q.Add(item.target.Kind, item.target.Id, item.label, format, api.DefaultClient.Options.Fallback); err == nil {
+					queued++
+				}
+			}
+
+			return fmt.Sprintf("Queued %d of %d, run 'queue run' to download them", queued, len(selection))
+		},
+	}
+
+	action, selection, err := p.run()
+	api.CheckErr(err)
+
+	if action != pickerDownload {
+		return
+	}
+
+	summary := newDownloadSummary()
+
+	var errs []error
+	for _, item := range selection {
+		err := downloadTarget(ctx, api.DefaultClient, item.target, format)
+		summary.exitIfInterrupted(ctx)
+
+		if err != nil {
+			errs = append(errs, err)
+			api.PrintColor(api.COLOR_RED, "%s %s: %s", item.target.Kind, item.target.Id, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		exitFailed(errs)
+	}
+}
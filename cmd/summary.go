@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"godab/api"
+	"os"
+	"sync"
+)
+
+type downloadSummary struct {
+	mu     sync.Mutex
+	done   []api.TrackResult
+	failed []api.TrackResult
+}
+
+func newDownloadSummary() *downloadSummary {
+	summary := &downloadSummary{}
+	api.DefaultClient.Options.OnTrackResult = summary.record
+	return summary
+}
+
+func (s *downloadSummary) record(result api.TrackResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result.Err != nil {
+		s.failed = append(s.failed, result)
+	} else {
+		s.done = append(s.done, result)
+	}
+}
+
+func (s *downloadSummary) print() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	api.PrintColor(api.COLOR_YELLOW, "\nDownload interrupted")
+
+	api.PrintColor(api.COLOR_GREEN, "Completed %d tracks", len(s.done))
+	for _, result := range s.done {
+		api.PrintColor(api.COLOR_GREEN, "  %s", result.Location)
+	}
+
+	api.PrintColor(api.COLOR_RED, "Not completed %d tracks", len(s.failed))
+	for _, result := range s.failed {
+		api.PrintColor(api.COLOR_RED, "  '%s' (ID: %d)", result.Track.Title, result.Track.Id)
+	}
+}
+
+// exitIfInterrupted prints the summary and exits when ctx was cancelled by
+// SIGINT or SIGTERM.
+func (s *downloadSummary) exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() == nil {
+		return
+	}
+
+	s.print()
+	os.Exit(api.ExitInterrupted)
+}
+
+// exitFailed exits with the code of the class of errs, see api.ExitCode,
+// or 1 when they don't belong to one.
+func exitFailed(errs []error) {
+	code := api.ExitCode(errors.Join(errs...))
+	if code == 0 {
+		code = api.ExitFailure
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"godab/api"
+
+	"github.com/spf13/cobra"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the account logged in on the endpoint",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		user, err := api.DefaultClient.WhoAmIContext(cmd.Context())
+
+		if errors.Is(err, api.ErrSessionExpired) {
+			exitUnauthorized("Your session expired. Run 'login' command again.")
+		}
+		api.CheckErr(err)
+
+		api.PrintColor(api.COLOR_GREEN, "Logged in on %s as %s (%s)", api.DefaultClient.Endpoint, user.Username, user.Email)
+
+		session, err := api.DefaultClient.StoredSession()
+		if err == nil && session != nil && !session.Expires.IsZero() {
+			api.PrintColor(api.COLOR_BLUE, "Session expires on %s", session.Expires.Local().Format("2006-01-02 15:04"))
+		}
+
+		if _, err := api.DefaultClient.StoredCredentials(); err == nil {
+			api.PrintColor(api.COLOR_BLUE, "Credentials stored, expired sessions are renewed automatically")
+		}
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:         "logout",
+	Short:       "Forget the session and stored credentials of the endpoint",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{offlineAnnotation: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		api.CheckErr(api.DefaultClient.Logout())
+		api.CheckErr(api.DefaultClient.ForgetCredentials())
+		api.PrintColor(api.COLOR_GREEN, "Logged out of %s", api.DefaultClient.Endpoint)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+	rootCmd.AddCommand(logoutCmd)
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultProfile = "default"
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+type Setting struct {
+	Key     string
+	Env     string
+	Default string
+}
+
+// Settings lists every configurable key. Values are resolved in the order
+// flag, env variable, profile and default.
+var Settings = []Setting{
+	{Key: "endpoint", Env: "DAB_ENDPOINT", Default: "https://dabmusic.xyz"},
+	{Key: "download_location", Env: "DOWNLOAD_LOCATION", Default: "."},
+	{Key: "format", Env: "DOWNLOAD_FORMAT", Default: "flac"},
+	{Key: "concurrency", Env: "CONCURRENCY", Default: "3"},
+	{Key: "album_concurrency", Env: "ALBUM_CONCURRENCY", Default: "2"},
+	{Key: "host_concurrency", Env: "HOST_CONCURRENCY", Default: "0"},
+	{Key: "rate_limit", Env: "RATE_LIMIT", Default: "10"},
+	{Key: "rate_burst", Env: "RATE_BURST", Default: "20"},
+	{Key: "album_template", Env: "ALBUM_TEMPLATE"},
+	{Key: "track_template", Env: "TRACK_TEMPLATE"},
+	{Key: "idle_conn_timeout", Env: "IDLE_CONN_TIMEOUT", Default: "120s"},
+	{Key: "tls_handshake_timeout", Env: "TLS_HANDSHAKE_TIMEOUT", Default: "120s"},
+	{Key: "expect_continue_timeout", Env: "EXPECT_CONTINUE_TIMEOUT", Default: "120s"},
+	{Key: "timeout", Env: "TIMEOUT", Default: "120s"},
+}
+
+type File struct {
+	Profile  string                       `yaml:"profile,omitempty"`
+	Profiles map[string]map[string]string `yaml:"profiles"`
+}
+
+var file File
+var activeProfile = DefaultProfile
+var flags = make(map[string]string)
+
+func GetConfigPath() string {
+	return filepath.Join(GetConfigDir(), "config.yaml")
+}
+
+// Load reads the config file and selects profile, falling back to
+// GODAB_PROFILE and then to the profile named in the file.
+func Load(profile string) error {
+	file = File{}
+
+	data, err := os.ReadFile(GetConfigPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	if err == nil {
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("unable to decode config file %s: %w", GetConfigPath(), err)
+		}
+	}
+
+	activeProfile = DefaultProfile
+	for _, candidate := range []string{profile, os.Getenv("GODAB_PROFILE"), file.Profile} {
+		if candidate != "" {
+			activeProfile = candidate
+			break
+		}
+	}
+
+	if _, ok := file.Profiles[activeProfile]; !ok && activeProfile != DefaultProfile {
+		return fmt.Errorf("%w: %s in %s", ErrProfileNotFound, activeProfile, GetConfigPath())
+	}
+
+	return nil
+}
+
+func GetProfile() string {
+	return activeProfile
+}
+
+// SetFlag records a value given on the command line, it wins over any
+// other source.
+func SetFlag(key string, value string) {
+	flags[key] = value
+}
+
+func lookup(key string) (Setting, error) {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("unknown config key %s", key)
+}
+
+// Default returns the value of key when nothing sets it.
+func Default(key string) string {
+	setting, _ := lookup(key)
+	return setting.Default
+}
+
+// Source returns the value of key and where it comes from.
+func Source(key string) (string, string) {
+	setting, err := lookup(key)
+	if err != nil {
+		return "", ""
+	}
+
+	if value, ok := flags[key]; ok {
+		return value, "flag"
+	}
+
+	if value := os.Getenv(setting.Env); value != "" {
+		return value, "env"
+	}
+
+	if value, ok := file.Profiles[activeProfile][key]; ok && value != "" {
+		return value, "profile"
+	}
+
+	return setting.Default, "default"
+}
+
+func Get(key string) string {
+	value, _ := Source(key)
+	return value
+}
+
+func validate(key string, value string) error {
+	switch key {
+	case "rate_limit":
+		if n, err := strconv.ParseFloat(value, 64); err != nil || n < 0 {
+			return fmt.Errorf("%s must be a number of requests per second, or 0 for no limit", key)
+		}
+	case "concurrency", "album_concurrency", "rate_burst":
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("%s must be a positive number", key)
+		}
+	case "host_concurrency":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("%s must be a positive number, or 0 for no limit", key)
+		}
+	case "idle_conn_timeout", "tls_handshake_timeout", "expect_continue_timeout", "timeout":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s must be a duration like 120s or 2m", key)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that key is a setting and value a valid one for it, an
+// empty value removes the setting.
+func Validate(key string, value string) error {
+	if _, err := lookup(key); err != nil {
+		return err
+	}
+
+	if value != "" {
+		return validate(key, value)
+	}
+
+	return nil
+}
+
+// Set stores key in the active profile of the config file, an empty value
+// removes it.
+func Set(key string, value string) error {
+	if err := Validate(key, value); err != nil {
+		return err
+	}
+
+	if file.Profiles == nil {
+		file.Profiles = make(map[string]map[string]string)
+	}
+
+	profile := file.Profiles[activeProfile]
+	if profile == nil {
+		profile = make(map[string]string)
+		file.Profiles[activeProfile] = profile
+	}
+
+	if value == "" {
+		delete(profile, key)
+	} else {
+		profile[key] = value
+	}
+
+	return save()
+}
+
+func save() error {
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("unable to encode config: %w", err)
+	}
+
+	if err := os.MkdirAll(GetConfigDir(), 0755); err != nil {
+		return fmt.Errorf("unable to create config dir: %w", err)
+	}
+
+	return os.WriteFile(GetConfigPath(), data, 0644)
+}
+
+func Profiles() []string {
+	var profiles []string
+	for name := range file.Profiles {
+		profiles = append(profiles, name)
+	}
+	slices.Sort(profiles)
+	return profiles
+}
@@ -2,67 +2,103 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 )
 
-var Env = map[string]string{
-	"DAB_ENDPOINT":      os.Getenv("DAB_ENDPOINT"),
-	"DOWNLOAD_LOCATION": os.Getenv("DOWNLOAD_LOCATION"),
-	"VERSION":           "1.0.0",
-}
+const Version = "1.0.0"
 
 func GetEndpoint() string {
-	endpoint := Env["DAB_ENDPOINT"]
-	if endpoint != "" {
-		return endpoint
-	}
-	return "https://dabmusic.xyz"
+	return Get("endpoint")
 }
 
 func GetDownloadLocation() string {
-	downloadLocation := Env["DOWNLOAD_LOCATION"]
-	if downloadLocation != "" {
-		return downloadLocation
+	return Get("download_location")
+}
+
+func GetFormat() string {
+	return Get("format")
+}
+
+func GetConcurrency() int {
+	if n, err := strconv.Atoi(Get("concurrency")); err == nil && n > 0 {
+		return n
 	}
-	return "."
+	return 3
+}
+
+func GetAlbumConcurrency() int {
+	if n, err := strconv.Atoi(Get("album_concurrency")); err == nil && n > 0 {
+		return n
+	}
+	return 2
+}
+
+func GetHostConcurrency() int {
+	if n, err := strconv.Atoi(Get("host_concurrency")); err == nil && n >= 0 {
+		return n
+	}
+	return 0
+}
+
+func GetRateLimit() float64 {
+	if n, err := strconv.ParseFloat(Get("rate_limit"), 64); err == nil && n >= 0 {
+		return n
+	}
+	return 10
+}
+
+func GetRateBurst() int {
+	if n, err := strconv.Atoi(Get("rate_burst")); err == nil && n > 0 {
+		return n
+	}
+	return 20
+}
+
+func GetAlbumTemplate() string {
+	return Get("album_template")
+}
+
+func GetTrackTemplate() string {
+	return Get("track_template")
+}
+
+func GetConfigDir() string {
+	if val := os.Getenv("GODAB_CONFIG_DIR"); val != "" {
+		return val
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(dir, "godab")
 }
 
 func GetVersion() string {
-	return Env["VERSION"]
+	return Version
 }
 
-func GetIdleConnTimeout() time.Duration {
-	if val := os.Getenv("IDLE_CONN_TIMEOUT"); val != "" {
-		if d, err := time.ParseDuration(val); err == nil {
-			return d
-		}
+func getDuration(key string) time.Duration {
+	if d, err := time.ParseDuration(Get(key)); err == nil {
+		return d
 	}
 	return 120 * time.Second
 }
 
+func GetIdleConnTimeout() time.Duration {
+	return getDuration("idle_conn_timeout")
+}
+
 func GetTLSHandshakeTimeout() time.Duration {
-	if val := os.Getenv("TLS_HANDSHAKE_TIMEOUT"); val != "" {
-		if d, err := time.ParseDuration(val); err == nil {
-			return d
-		}
-	}
-	return 120 * time.Second
+	return getDuration("tls_handshake_timeout")
 }
 
 func GetExpectContinueTimeout() time.Duration {
-	if val := os.Getenv("EXPECT_CONTINUE_TIMEOUT"); val != "" {
-		if d, err := time.ParseDuration(val); err == nil {
-			return d
-		}
-	}
-	return 120 * time.Second
+	return getDuration("expect_continue_timeout")
 }
 
 func GetTimeout() time.Duration {
-	if val := os.Getenv("TIMEOUT"); val != "" {
-		if d, err := time.ParseDuration(val); err == nil {
-			return d
-		}
-	}
-	return 120 * time.Second
+	return getDuration("timeout")
 }
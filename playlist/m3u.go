@@ -0,0 +1,56 @@
+package playlist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Item is a line of a written playlist. Path is absolute or relative to the
+// working directory, Duration is in seconds.
+type Item struct {
+	Path     string
+	Title    string
+	Duration int
+}
+
+// WriteM3U8 writes items to path with locations relative to the playlist,
+// so it keeps working when the whole library is moved.
+func WriteM3U8(path string, name string, items []Item) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("unable to resolve playlist dir: %w", err)
+	}
+
+	var out strings.Builder
+	out.WriteString("#EXTM3U\n")
+	if name != "" {
+		fmt.Fprintf(&out, "#PLAYLIST:%s\n", name)
+	}
+
+	for _, item := range items {
+		location, err := filepath.Abs(item.Path)
+		if err != nil {
+			return fmt.Errorf("unable to resolve %s: %w", item.Path, err)
+		}
+
+		if rel, err := filepath.Rel(dir, location); err == nil {
+			location = rel
+		}
+
+		duration := item.Duration
+		if duration <= 0 {
+			duration = -1
+		}
+
+		fmt.Fprintf(&out, "#EXTINF:%d,%s\n", duration, item.Title)
+		out.WriteString(filepath.ToSlash(location) + "\n")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create playlist dir: %w", err)
+	}
+
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
@@ -0,0 +1,308 @@
+package playlist
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Entry is a track of a playlist exported from another service. Duration is
+// in seconds, fields the file doesn't provide are left empty.
+type Entry struct {
+	Row      int
+	Title    string
+	Artist   string
+	Album    string
+	Duration int
+	ISRC     string
+}
+
+type Playlist struct {
+	Name    string
+	Entries []Entry
+}
+
+// Read parses a CSV, M3U, XSPF or JSPF playlist, picked by file extension.
+func Read(path string) (*Playlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open playlist: %w", err)
+	}
+	defer file.Close()
+
+	p := &Playlist{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		err = p.readCSV(file)
+	case ".m3u", ".m3u8":
+		err = p.readM3U(file)
+	case ".xspf":
+		err = p.readXSPF(file)
+	case ".jspf", ".json":
+		err = p.readJSPF(file)
+	default:
+		return nil, fmt.Errorf("unsupported playlist format %s, use csv, m3u, xspf or jspf", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read playlist %s: %w", path, err)
+	}
+
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return p, nil
+}
+
+func (p *Playlist) add(entry Entry) {
+	entry.Title = strings.TrimSpace(entry.Title)
+	entry.Artist = strings.TrimSpace(entry.Artist)
+	entry.Album = strings.TrimSpace(entry.Album)
+	entry.ISRC = strings.TrimSpace(entry.ISRC)
+
+	if entry.Title == "" && entry.ISRC == "" {
+		return
+	}
+
+	entry.Row = len(p.Entries) + 1
+	p.Entries = append(p.Entries, entry)
+}
+
+// csvColumns maps the header names used by common exports to Entry fields.
+var csvColumns = map[string]string{
+	"title":          "title",
+	"track":          "title",
+	"name":           "title",
+	"track name":     "title",
+	"song":           "title",
+	"artist":         "artist",
+	"artists":        "artist",
+	"artist name":    "artist",
+	"artist name(s)": "artist",
+	"album":          "album",
+	"album name":     "album",
+	"duration":       "duration",
+	"length":         "duration",
+	"duration_ms":    "duration_ms",
+	"duration (ms)":  "duration_ms",
+	"isrc":           "isrc",
+}
+
+func (p *Playlist) readCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("missing header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[name]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+
+	if _, ok := columns["title"]; !ok {
+		if _, ok := columns["isrc"]; !ok {
+			return fmt.Errorf("no title or isrc column in header")
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		get := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		duration := parseDuration(get("duration"))
+		if ms, err := strconv.Atoi(strings.TrimSpace(get("duration_ms"))); err == nil {
+			duration = ms / 1000
+		}
+
+		p.add(Entry{
+			Title:    get("title"),
+			Artist:   get("artist"),
+			Album:    get("album"),
+			Duration: duration,
+			ISRC:     get("isrc"),
+		})
+	}
+
+	return nil
+}
+
+// parseDuration reads "m:ss", "h:mm:ss" or a number of seconds.
+func parseDuration(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	seconds := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + int(n)
+	}
+
+	return seconds
+}
+
+func (p *Playlist) readM3U(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	var pending *Entry
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			p.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			length, display, _ := strings.Cut(info, ",")
+
+			// attributes like tvg-id="" may follow the length
+			length, _, _ = strings.Cut(length, " ")
+
+			entry := splitDisplay(display)
+			if seconds, err := strconv.Atoi(length); err == nil && seconds > 0 {
+				entry.Duration = seconds
+			}
+			pending = &entry
+		case strings.HasPrefix(line, "#"):
+		default:
+			if pending == nil {
+				name := strings.TrimSuffix(filepath.Base(line), filepath.Ext(line))
+				entry := splitDisplay(name)
+				pending = &entry
+			}
+
+			p.add(*pending)
+			pending = nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// splitDisplay reads the usual "Artist - Title" form.
+func splitDisplay(display string) Entry {
+	if artist, title, found := strings.Cut(display, " - "); found {
+		return Entry{Artist: artist, Title: title}
+	}
+	return Entry{Title: display}
+}
+
+func (p *Playlist) readXSPF(r io.Reader) error {
+	var doc struct {
+		Title  string `xml:"title"`
+		Tracks []struct {
+			Title      string `xml:"title"`
+			Creator    string `xml:"creator"`
+			Album      string `xml:"album"`
+			Duration   int    `xml:"duration"`
+			Identifier string `xml:"identifier"`
+		} `xml:"trackList>track"`
+	}
+
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+
+	p.Name = strings.TrimSpace(doc.Title)
+	for _, track := range doc.Tracks {
+		p.add(Entry{
+			Title:    track.Title,
+			Artist:   track.Creator,
+			Album:    track.Album,
+			Duration: track.Duration / 1000,
+			ISRC:     isrcFromIdentifier(track.Identifier),
+		})
+	}
+
+	return nil
+}
+
+func (p *Playlist) readJSPF(r io.Reader) error {
+	var doc struct {
+		Playlist struct {
+			Title  string `json:"title"`
+			Tracks []struct {
+				Title      string          `json:"title"`
+				Creator    string          `json:"creator"`
+				Album      string          `json:"album"`
+				Duration   int             `json:"duration"`
+				Identifier json.RawMessage `json:"identifier"`
+			} `json:"track"`
+		} `json:"playlist"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+
+	p.Name = strings.TrimSpace(doc.Playlist.Title)
+	for _, track := range doc.Playlist.Tracks {
+		// identifier is a string or a list of strings depending on the exporter
+		var identifiers []string
+		if json.Unmarshal(track.Identifier, &identifiers) != nil {
+			var identifier string
+			json.Unmarshal(track.Identifier, &identifier)
+			identifiers = []string{identifier}
+		}
+
+		var isrc string
+		for _, identifier := range identifiers {
+			if isrc = isrcFromIdentifier(identifier); isrc != "" {
+				break
+			}
+		}
+
+		p.add(Entry{
+			Title:    track.Title,
+			Artist:   track.Creator,
+			Album:    track.Album,
+			Duration: track.Duration / 1000,
+			ISRC:     isrc,
+		})
+	}
+
+	return nil
+}
+
+// isrcFromIdentifier extracts the code of "isrc:XXX" style identifiers.
+func isrcFromIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if len(identifier) > 5 && strings.EqualFold(identifier[:5], "isrc:") {
+		return strings.ToUpper(identifier[5:])
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Playlist
+	}{
+		{
+			name: "Liked.csv",
+			content: "\ufeffTrack Name,Artist Name(s),Album Name,Duration (ms),ISRC\n" +
+				"Song A,Artist A,Album A,215000,usabc1234567\n" +
+				",,,,\n" +
+				"\"Song, B\",Artist B,,61500,\n",
+			want: Playlist{Name: "Liked", Entries: []Entry{
+				{Row: 1, Title: "Song A", Artist: "Artist A", Album: "Album A", Duration: 215, ISRC: "usabc1234567"},
+				{Row: 2, Title: "Song, B", Artist: "Artist B", Duration: 61},
+			}},
+		},
+		{
+			name:    "durations.csv",
+			content: "title,artist,length\nSong A,Artist A,3:05\nSong B,Artist B,1:02:03\n",
+			want: Playlist{Name: "durations", Entries: []Entry{
+				{Row: 1, Title: "Song A", Artist: "Artist A", Duration: 185},
+				{Row: 2, Title: "Song B", Artist: "Artist B", Duration: 3723},
+			}},
+		},
+		{
+			name: "mix.m3u8",
+			content: "#EXTM3U\n#PLAYLIST:Road Trip\n" +
+				"#EXTINF:215 tvg-id=\"\",Artist A - Song A\n/music/a.flac\n" +
+				"\n/music/Artist B - Song B.mp3\n" +
+				"#EXTINF:-1,Song C\nhttp://example.com/c\n",
+			want: Playlist{Name: "Road Trip", Entries: []Entry{
+				{Row: 1, Title: "Song A", Artist: "Artist A", Duration: 215},
+				{Row: 2, Title: "Song B", Artist: "Artist B"},
+				{Row: 3, Title: "Song C"},
+			}},
+		},
+		{
+			name: "list.xspf",
+			content: `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title>Favorites</title>
+  <trackList>
+    <track><title>Song A</title><creator>Artist A</creator><album>Album A</album><duration>215000</duration><identifier>isrc:usabc1234567</identifier></track>
+    <track><title>Song B</title><creator>Artist B</creator></track>
+  </trackList>
+</playlist>`,
+			want: Playlist{Name: "Favorites", Entries: []Entry{
+				{Row: 1, Title: "Song A", Artist: "Artist A", Album: "Album A", Duration: 215, ISRC: "USABC1234567"},
+				{Row: 2, Title: "Song B", Artist: "Artist B"},
+			}},
+		},
+		{
+			name: "list.jspf",
+			content: `{"playlist": {"title": "Export", "track": [
+				{"title": "Song A", "creator": "Artist A", "album": "Album A", "duration": 215000, "identifier": ["https://example.com/1", "isrc:USABC1234567"]},
+				{"title": "Song B", "creator": "Artist B", "identifier": "isrc:usxyz7654321"},
+				{"creator": "Nobody"}
+			]}}`,
+			want: Playlist{Name: "Export", Entries: []Entry{
+				{Row: 1, Title: "Song A", Artist: "Artist A", Album: "Album A", Duration: 215, ISRC: "USABC1234567"},
+				{Row: 2, Title: "Song B", Artist: "Artist B", ISRC: "USXYZ7654321"},
+			}},
+		},
+	}
+
+	dir := t.TempDir()
+
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Read(path)
+		if err != nil {
+			t.Errorf("Read(%s): %v", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("Read(%s) = %+v, want %+v", test.name, *got, test.want)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"list.txt", "Song A\n"},
+		{"noheader.csv", "artist,album\nArtist A,Album A\n"},
+		{"broken.xspf", "<playlist><trackList>"},
+		{"broken.jspf", "{"},
+	}
+
+	dir := t.TempDir()
+
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Read(path); err == nil {
+			t.Errorf("Read(%s) succeeded, want an error", test.name)
+		}
+	}
+
+	if _, err := Read(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Errorf("Read of a missing file succeeded, want an error")
+	}
+}
@@ -0,0 +1,255 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"godab/api"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+type TrackState struct {
+	Id     api.ID `json:"id"`
+	Title  string `json:"title"`
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Job struct {
+	Id       int          `json:"id"`
+	Kind     string       `json:"kind"`
+	EntityId string       `json:"entityId"`
+	Title    string       `json:"title"`
+	Format   int          `json:"format"`
+	Fallback []int        `json:"fallback,omitempty"`
+	Status   Status       `json:"status"`
+	Error    string       `json:"error,omitempty"`
+	Tracks   []TrackState `json:"tracks,omitempty"`
+	AddedAt  time.Time    `json:"addedAt"`
+}
+
+// Queue is the list of download jobs persisted in a JSON file. Every
+// mutating method saves the file right away so a crash loses nothing.
+type Queue struct {
+	NextId int    `json:"nextId"`
+	Jobs   []*Job `json:"jobs"`
+
+	path string
+	mu   sync.Mutex
+}
+
+func Open(path string) (*Queue, error) {
+	q := &Queue{path: path, NextId: 1}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read queue file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, q); err != nil {
+		return nil, fmt.Errorf("unable to decode queue file %s: %w", path, err)
+	}
+
+	return q, nil
+}
+
+func (q *Queue) save() error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode queue: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return fmt.Errorf("unable to create queue dir: %w", err)
+	}
+
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("unable to write queue file: %w", err)
+	}
+
+	return os.Rename(tmp, q.path)
+}
+
+func (q *Queue) Add(kind string, entityId string, title string, format int, fallback []int) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, job := range q.Jobs {
+		if job.Kind == kind && job.EntityId == entityId && job.Status != StatusDone {
+			return nil, fmt.Errorf("%s %s is already queued as job %d", kind, entityId, job.Id)
+		}
+	}
+
+	job := &Job{
+		Id:       q.NextId,
+		Kind:     kind,
+		EntityId: entityId,
+		Title:    title,
+		Format:   format,
+		Fallback: fallback,
+		Status:   StatusPending,
+		AddedAt:  time.Now(),
+	}
+
+	q.NextId++
+	q.Jobs = append(q.Jobs, job)
+
+	return job, q.save()
+}
+
+// Runnable returns the jobs a run has to process, including the ones left
+// running by a crashed or interrupted run.
+func (q *Queue) Runnable() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var jobs []*Job
+	for _, job := range q.Jobs {
+		if job.Status == StatusPending || job.Status == StatusRunning {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+func (q *Queue) SetStatus(job *Job, status Status, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job.Status = status
+	job.Error = ""
+	if err != nil {
+		job.Error = err.Error()
+	}
+
+	return q.save()
+}
+
+// SetTracks records the tracks of a job, keeping the state of the ones
+// already known.
+func (q *Queue) SetTracks(job *Job, tracks []api.Track) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, track := range tracks {
+		if job.track(track.Id) == nil {
+			job.Tracks = append(job.Tracks, TrackState{
+				Id:     track.Id,
+				Title:  track.Title,
+				Status: StatusPending,
+			})
+		}
+	}
+
+	return q.save()
+}
+
+func (q *Queue) RecordTrack(job *Job, result api.TrackResult) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	state := job.track(result.Track.Id)
+	if state == nil {
+		job.Tracks = append(job.Tracks, TrackState{
+			Id:    result.Track.Id,
+			Title: result.Track.Title,
+		})
+		state = &job.Tracks[len(job.Tracks)-1]
+	}
+
+	state.Status = StatusDone
+	state.Error = ""
+	if result.Err != nil {
+		state.Status = StatusFailed
+		state.Error = result.Err.Error()
+	}
+
+	return q.save()
+}
+
+// RetryFailed puts failed jobs and their failed tracks back to pending and
+// returns how many jobs were reset.
+func (q *Queue) RetryFailed() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := 0
+	for _, job := range q.Jobs {
+		if job.Status != StatusFailed {
+			continue
+		}
+
+		job.Status = StatusPending
+		job.Error = ""
+		for i := range job.Tracks {
+			if job.Tracks[i].Status == StatusFailed {
+				job.Tracks[i].Status = StatusPending
+				job.Tracks[i].Error = ""
+			}
+		}
+		count++
+	}
+
+	return count, q.save()
+}
+
+// Clear removes every job, or only the finished ones when onlyDone is set.
+func (q *Queue) Clear(onlyDone bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var kept []*Job
+	if onlyDone {
+		for _, job := range q.Jobs {
+			if job.Status != StatusDone {
+				kept = append(kept, job)
+			}
+		}
+	}
+
+	q.Jobs = kept
+	return q.save()
+}
+
+func (job *Job) track(id api.ID) *TrackState {
+	for i := range job.Tracks {
+		if job.Tracks[i].Id == id {
+			return &job.Tracks[i]
+		}
+	}
+	return nil
+}
+
+// IsTrackDone reports whether the track was already downloaded by a
+// previous run of the job.
+func (job *Job) IsTrackDone(id api.ID) bool {
+	state := job.track(id)
+	return state != nil && state.Status == StatusDone
+}
+
+func (job *Job) CountTracks(status Status) int {
+	count := 0
+	for _, state := range job.Tracks {
+		if state.Status == status {
+			count++
+		}
+	}
+	return count
+}